@@ -1,23 +1,30 @@
 package api
 
 import (
-	"log"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
 	"net"
 	"net/http"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"time"
 
-	"encoding/json"
-	"io/ioutil"
+	"io"
 
 	"context"
-	"strings"
 
-	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/logger"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/requestid"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/viewer"
 	"github.com/gorilla/mux"
 	"github.com/rcrowley/go-metrics"
-	"github.com/rcrowley/go-metrics/exp"
 )
 
 type UAAClient interface {
@@ -25,12 +32,24 @@ type UAAClient interface {
 }
 
 type Server struct {
-	httpServer         *http.Server
-	uaaClient          UAAClient
-	Endpoints          []*Endpoint
-	hostname           string
-	templatesDirectory string
-	logRequest         requestLogger
+	httpServer      *http.Server
+	adminServer     *http.Server
+	uaaClient       UAAClient
+	Endpoints       []*Endpoint
+	templates       *viewer.Renderer
+	templatesErr    error
+	assets          *assetServer
+	logRequest      requestLogger
+	shutdownTimeout time.Duration
+	onShutdown      []func()
+	tlsConfig       *TLSConfig
+	middleware      []Middleware
+	panics          metrics.Counter
+	logger          *logger.Logger
+	metrics         *requestMetrics
+	maxBodyBytes    int64
+	encoders        map[string]Encoder
+	cors            *CORSConfig
 }
 
 type requestLogger func(req Request, resp Response, endpoint *Endpoint, startTime time.Time, totalTime time.Duration)
@@ -42,15 +61,54 @@ type Config struct {
 	Endpoints          []*Endpoint
 	LogRequest         requestLogger
 	TemplatesDirectory string
-	AssetsDirectory    string
+	// TemplatesFS, if set, is used instead of TemplatesDirectory, e.g. to
+	// serve templates embedded in the binary.
+	TemplatesFS fs.FS
+	// TemplatesReload parses the templates again for every HTMLTemplate
+	// response, for development. Templates that are broken at startup then
+	// work once they are fixed.
+	TemplatesReload bool
+	AssetsDirectory string
+	// AssetsFS, if set, is used instead of AssetsDirectory.
+	AssetsFS fs.FS
+	// AssetsCacheControl is sent with assets requested by their plain URL and
+	// defaults to no-cache, so clients revalidate with the ETag. Fingerprinted
+	// URLs from the asset template func are always cacheable for a year.
+	AssetsCacheControl string
+	ShutdownTimeout    time.Duration
+	OnShutdown         []func()
+	TLS                *TLSConfig
+	Middleware         []Middleware
+	Logger             *logger.Logger
+	Prometheus         *PrometheusConfig
+	Debug              *DebugConfig
+	Compression        *CompressionConfig
+	CORS               *CORSConfig
+	// MaxBodyBytes limits the size of request bodies. Endpoints can override
+	// it, and zero means no limit.
+	MaxBodyBytes int64
+	// Encoders adds to or replaces DefaultEncoders, keyed by media type, and a
+	// nil Encoder removes one. The encoder is picked from the request's Accept
+	// header. JSON cannot be removed, since it is sent when the client accepts
+	// nothing else.
+	Encoders map[string]Encoder
 }
 
+const defaultShutdownTimeout = 10 * time.Second
+
 func New(apiConfig Config) *Server {
 	router := mux.NewRouter()
-	router.Handle("/debug/metrics", exp.ExpHandler(metrics.DefaultRegistry)).Methods("GET")
+
+	if apiConfig.Logger == nil {
+		apiConfig.Logger = logger.New("api", os.Stdout, logger.INFO)
+	}
 
 	if apiConfig.LogRequest == nil {
-		apiConfig.LogRequest = func(req Request, resp Response, endpoint *Endpoint, startTime time.Time, totalTime time.Duration) {}
+		apiConfig.LogRequest = defaultRequestLogger
+	}
+
+	if apiConfig.ShutdownTimeout == 0 {
+		apiConfig.ShutdownTimeout = defaultShutdownTimeout
 	}
 
 	server := &Server{
@@ -58,112 +116,477 @@ func New(apiConfig Config) *Server {
 			Addr:    net.JoinHostPort("", apiConfig.Port),
 			Handler: router,
 		},
-		uaaClient:          apiConfig.UAAClient,
-		logRequest:         apiConfig.LogRequest,
-		hostname:           apiConfig.Hostname,
-		templatesDirectory: apiConfig.TemplatesDirectory,
+		uaaClient:       apiConfig.UAAClient,
+		logRequest:      apiConfig.LogRequest,
+		shutdownTimeout: apiConfig.ShutdownTimeout,
+		onShutdown:      apiConfig.OnShutdown,
+		tlsConfig:       apiConfig.TLS,
+		middleware:      apiConfig.Middleware,
+		panics:          metrics.GetOrRegisterCounter("api.panics", metrics.DefaultRegistry),
+		logger:          apiConfig.Logger,
+		maxBodyBytes:    apiConfig.MaxBodyBytes,
+		encoders:        DefaultEncoders(),
+		cors:            apiConfig.CORS,
+	}
+
+	err := apiConfig.CORS.validate()
+	if err != nil {
+		panic(err.Error())
 	}
 
-	router.Handle("/assets/{rest}", http.StripPrefix("/assets/", http.FileServer(http.Dir(apiConfig.AssetsDirectory))))
+	for mediaType, encoder := range apiConfig.Encoders {
+		if encoder == nil && mediaType == jsonMediaType {
+			panic("Encoders cannot remove " + jsonMediaType)
+		}
+
+		if encoder == nil {
+			delete(server.encoders, mediaType)
+			continue
+		}
+
+		server.encoders[mediaType] = encoder
+	}
+
+	if apiConfig.AssetsFS == nil {
+		assetsDirectory := apiConfig.AssetsDirectory
+		if assetsDirectory == "" {
+			// http.Dir served the working directory for an empty path, while
+			// os.DirFS would serve the root
+			assetsDirectory = "."
+		}
+		apiConfig.AssetsFS = os.DirFS(assetsDirectory)
+	}
+	server.assets = newAssetServer(apiConfig.AssetsFS, apiConfig.AssetsCacheControl)
+
+	if apiConfig.TemplatesFS == nil && apiConfig.TemplatesDirectory != "" {
+		apiConfig.TemplatesFS = os.DirFS(apiConfig.TemplatesDirectory)
+	}
+
+	if apiConfig.TemplatesFS != nil {
+		server.templates, server.templatesErr = viewer.New(viewer.Config{
+			FS: apiConfig.TemplatesFS,
+			Funcs: template.FuncMap{
+				"hostname": func() string { return apiConfig.Hostname },
+				"asset":    server.assets.URL,
+			},
+			Reload: apiConfig.TemplatesReload,
+		})
+		if server.templatesErr != nil {
+			server.logger.Error("templates-load-failed", server.templatesErr)
+		}
+	}
+
+	if apiConfig.Compression != nil {
+		server.httpServer.Handler = compress(*apiConfig.Compression, router)
+	}
+
+	server.registerDebug(router, apiConfig.Debug)
+
+	if apiConfig.Prometheus != nil {
+		server.registerPrometheus(router, apiConfig.Prometheus)
+	}
+
+	router.PathPrefix("/assets/").Handler(server.assets).Methods(http.MethodGet, http.MethodHead)
+	var paths []string
+	endpointsByPath := map[string][]*Endpoint{}
 	for _, e := range apiConfig.Endpoints {
+		err = e.Auth.Validate()
+		if err == nil {
+			err = e.CORS.validate()
+		}
+		if err != nil {
+			panic(fmt.Sprintf("endpoint %s %s: %v", e.Method, e.Path, err))
+		}
+
 		router.Handle(e.Path, server.handle(e)).Methods(e.Method)
+
+		if endpointsByPath[e.Path] == nil {
+			paths = append(paths, e.Path)
+		}
+		endpointsByPath[e.Path] = append(endpointsByPath[e.Path], e)
+	}
+
+	for _, p := range paths {
+		if server.needsPreflight(endpointsByPath[p]) {
+			router.Handle(p, server.preflight(endpointsByPath[p])).Methods(http.MethodOptions)
+		}
 	}
 
 	return server
 }
 
+// Run binds the configured port and serves requests until ctx is done. A
+// failure to bind, or to load the TLS configuration, is returned immediately.
+// The admin port from DebugConfig, if any, is bound and served alongside the
+// main one. Once ctx is done the server stops accepting connections, waits up
+// to ShutdownTimeout for in-flight requests to finish and then calls the
+// OnShutdown callbacks.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	if s.tlsConfig != nil {
+		tlsConfig, err := s.tlsConfig.build()
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	var adminListener net.Listener
+	if s.adminServer != nil {
+		adminListener, err = net.Listen("tcp", s.adminServer.Addr)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- s.httpServer.Serve(listener)
+	}()
+
+	fields := logger.Fields{"address": listener.Addr().String()}
+	if adminListener != nil {
+		go func() {
+			serveErr <- s.adminServer.Serve(adminListener)
+		}()
+
+		fields["admin_address"] = adminListener.Addr().String()
+	}
+
+	s.logger.Info("started", fields)
+
+	select {
+	case err := <-serveErr:
+		s.httpServer.Close()
+		if s.adminServer != nil {
+			s.adminServer.Close()
+		}
+		return err
+	case <-ctx.Done():
+		return s.shutdown()
+	}
+}
+
+// Start runs the server in the background. The returned func shuts it down
+// gracefully and blocks until it has stopped.
 func (s *Server) Start() func() {
-	go s.httpServer.ListenAndServe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
 
-	log.Println("HTTP metrics now available at /debug/metrics")
+	go func() {
+		defer close(done)
+
+		err := s.Run(ctx)
+		if err != nil {
+			s.logger.Error("run-failed", err)
+		}
+	}()
 
 	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		// the deadline passed with requests still running, so cut them off
 		s.httpServer.Close()
 	}
+
+	if s.adminServer != nil {
+		// debug requests such as profiles are not worth waiting for
+		s.adminServer.Close()
+	}
+
+	for _, f := range s.onShutdown {
+		f()
+	}
+
+	return err
 }
 
 func (s *Server) handle(endpoint *Endpoint) http.HandlerFunc {
+	handle := chain(endpoint.Handle, s.middleware, endpoint.Middleware)
+
+	maxBodyBytes := s.maxBodyBytes
+	if endpoint.MaxBodyBytes != 0 {
+		maxBodyBytes = endpoint.MaxBodyBytes
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		token = strings.TrimPrefix(token, "bearer ")
-		token = strings.TrimPrefix(token, "Bearer ")
+		id := requestid.FromRequest(r)
+		w.Header().Set(requestid.Header, id)
+		writeCORSHeaders(s.endpointCORS(endpoint), w, r)
 
-		currentUser, _ := s.uaaClient.CheckToken(token, context.Background())
 		req := &realRequest{
-			httpRequest: r,
-			currentUser: currentUser,
+			httpRequest:  r.WithContext(requestid.NewContext(r.Context(), id)),
+			id:           id,
+			uaaClient:    s.uaaClient,
+			logger:       s.logger.WithRequestID(id),
+			maxBodyBytes: maxBodyBytes,
+			decoding:     endpoint.DecodeOptions,
 		}
 
 		start := time.Now()
-		var resp Response
+		done := s.metrics.begin(endpoint)
+		resp := s.respond(endpoint, handle, req)
 
-		if s.passesAuth(endpoint.Auth, currentUser) {
-			resp = *endpoint.Handle(req)
-		} else {
-			resp = Response{
-				StatusCode: http.StatusUnauthorized,
-			}
+		resp, aborted := s.send(&responseRecorder{ResponseWriter: w, status: resp.StatusCode}, req, resp)
+		done(resp.StatusCode)
+		s.logRequest(req, resp, endpoint, start, time.Since(start))
+
+		if aborted {
+			panic(http.ErrAbortHandler)
 		}
+	}
+}
 
-		s.writeResponse(w, resp)
-		s.logRequest(req, resp, endpoint, start, time.Since(start))
+func defaultRequestLogger(req Request, resp Response, endpoint *Endpoint, startTime time.Time, totalTime time.Duration) {
+	fields := logger.Fields{
+		"method":      endpoint.Method,
+		"route":       endpoint.Path,
+		"path":        req.Path(),
+		"status":      resp.StatusCode,
+		"bytes":       resp.BytesWritten,
+		"duration_ms": float64(totalTime) / float64(time.Millisecond),
+	}
+
+	if resp.Err != nil {
+		req.Logger().Error("request", resp.Err, fields)
+		return
 	}
+
+	req.Logger().Info("request", fields)
 }
 
-func (s *Server) writeResponse(w http.ResponseWriter, resp Response) {
-	var bodyBytes []byte
+// respond runs the auth and body checks and the endpoint. A panic, or a nil
+// Response from the endpoint, becomes a 500 so the request is still answered
+// and logged.
+func (s *Server) respond(endpoint *Endpoint, handle Handler, req *realRequest) (resp Response) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		if p == http.ErrAbortHandler {
+			panic(p)
+		}
+
+		resp = *ServerError(errors.New(http.StatusText(http.StatusInternalServerError)))
+		resp.Err = s.recordPanic(req, p)
+	}()
+
+	authResp := s.checkAuth(endpoint, req)
+	if authResp != nil {
+		return *authResp
+	}
+
+	bodyResp := checkBody(endpoint, req)
+	if bodyResp != nil {
+		return *bodyResp
+	}
+
+	r := handle(req)
+	if r == nil {
+		panic("endpoint returned a nil response")
+	}
+
+	if _, ok := req.bodyErr.(bodyTooLargeError); ok {
+		// whatever the endpoint made of the failed read, the client needs to
+		// know the body was too large
+		return *RequestEntityTooLarge(req.bodyErr)
+	}
+
+	return *r
+}
+
+// recordPanic counts and logs a recovered panic, returning it as the error to
+// log the request with.
+func (s *Server) recordPanic(req *realRequest, p interface{}) error {
+	s.panics.Inc(1)
+
+	err := fmt.Errorf("panic: %v", p)
+	req.Logger().Error("panic", err, logger.Fields{
+		"method": req.httpRequest.Method,
+		"path":   req.Path(),
+		"stack":  string(debug.Stack()),
+	})
+
+	return err
+}
+
+// send writes resp like writeResponse, recovering from panics while the body
+// is written, e.g. in a Stream. If nothing has been sent yet the client gets a
+// 500. Otherwise aborted is set, so the connection can be cut once the request
+// is logged and the client does not take a truncated body for a whole one.
+func (s *Server) send(w *responseRecorder, req *realRequest, resp Response) (sent Response, aborted bool) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		err := http.ErrAbortHandler
+		if p != http.ErrAbortHandler {
+			err = s.recordPanic(req, p)
+		}
+
+		if w.wroteHeader || p == http.ErrAbortHandler {
+			sent = resp
+			sent.StatusCode = w.status
+			sent.BytesWritten = w.bytes
+			sent.Err = err
+			aborted = true
+			return
+		}
+
+		for k := range resp.Header {
+			w.Header().Del(k)
+		}
+		w.Header().Del("Content-Length")
+
+		sent = s.writeResponse(w, req.httpRequest, *ServerError(errors.New(http.StatusText(http.StatusInternalServerError))))
+		sent.Err = err
+	}()
+
+	return s.writeResponse(w, req.httpRequest, resp), false
+}
+
+// writeResponse sends resp and returns it as it was sent, with the status code
+// and the number of body bytes. Err is set if the body could not be written.
+func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, resp Response) Response {
+	recorder := &responseRecorder{ResponseWriter: w, status: resp.StatusCode}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+
+	var err error
+	switch body := resp.Body.(type) {
+	case Stream:
+		err = body(recorder)
+	case NDJSON:
+		err = body.write(recorder)
+	case io.Reader:
+		err = writeReader(recorder, body)
+	default:
+		err = s.writeBody(recorder, r, resp)
+	}
+
+	if err != nil && resp.Err == nil {
+		resp.Err = err
+	}
+
+	// a stream that wrote nothing still needs its status sent
+	recorder.WriteHeader(recorder.status)
+
+	resp.StatusCode = recorder.status
+	resp.BytesWritten = recorder.bytes
+
+	return resp
+}
+
+func (s *Server) writeBody(w http.ResponseWriter, r *http.Request, resp Response) error {
+	var (
+		bodyBytes []byte
+		err       error
+	)
 	status := resp.StatusCode
 
 	if resp.Body != nil {
 		switch body := resp.Body.(type) {
 
 		case HTMLTemplate:
-			f, err := ioutil.ReadFile(s.templatesDirectory + "/" + body.Name + ".html")
+			var b bytes.Buffer
+			err = s.renderTemplate(&b, body)
 			if err != nil {
 				status = http.StatusInternalServerError
 			} else {
-				bodyBytes = []byte(viewer.Parse(string(f), map[string]string{"hostname": s.hostname}))
+				bodyBytes = b.Bytes()
 				w.Header().Set("Content-Type", "text/html")
 			}
 
 		default:
-			b, err := json.Marshal(resp.Body)
+			addVary(w.Header(), "Accept")
+
+			mediaType, ok := negotiate(r, s.encoders)
+			if !ok {
+				mediaType = jsonMediaType
+
+				// error responses are more use to the client in JSON than
+				// replaced by a 406
+				if status < http.StatusBadRequest {
+					status = http.StatusNotAcceptable
+					resp.Body = wrapError(fmt.Errorf("Accept must allow one of: %s", strings.Join(s.mediaTypes(), ", ")))
+				}
+			}
+
+			encode := s.encoders[mediaType]
+			if mediaType == jsonMediaType && wantsPretty(r) {
+				encode = EncodePrettyJSON
+			}
+
+			var b []byte
+			b, err = encode(resp.Body)
 			if err != nil {
 				status = http.StatusInternalServerError
 			} else {
 				bodyBytes = b
-				w.Header().Set("Content-Type", "application/json")
+				if strings.HasPrefix(mediaType, "text/") {
+					mediaType += "; charset=utf-8"
+				}
+				w.Header().Set("Content-Type", mediaType)
 			}
 		}
 	}
 
 	w.WriteHeader(status)
 	w.Write(bodyBytes)
+
+	return err
 }
 
-func (s *Server) passesAuth(authConfig *auth.Config, currentUser *uaaclient.User) bool {
-	if authConfig.AuthType != auth.NONE {
-		if currentUser == nil {
-			return false
-		}
+func (s *Server) renderTemplate(w io.Writer, body HTMLTemplate) error {
+	if s.templatesErr != nil {
+		return s.templatesErr
+	}
 
-		for _, s := range authConfig.Scopes {
-			for _, authScope := range currentUser.Scopes {
-				if s == authScope {
-					return true
-				}
-			}
-		}
+	if s.templates == nil {
+		return errors.New("no templates configured")
+	}
 
-		// none of the user's scopes matched the expected scopes
-		if len(authConfig.Scopes) > 0 {
-			return false
-		}
+	return s.templates.Render(w, body.Name, body.Layout, body.Data)
+}
+
+func (s *Server) mediaTypes() []string {
+	mediaTypes := make([]string, 0, len(s.encoders))
+	for mediaType := range s.encoders {
+		mediaTypes = append(mediaTypes, mediaType)
 	}
+	sort.Strings(mediaTypes)
 
-	return true
+	return mediaTypes
 }
 
+// HTMLTemplate is a response body rendered from the template called Name in
+// TemplatesDirectory, with Data as its dot. Layout, if set, names the layout
+// under layouts/ to render the page inside. The hostname func gives the
+// configured Hostname and the asset func the fingerprinted URL of an asset.
 type HTMLTemplate struct {
-	Name string
+	Name   string
+	Layout string
+	Data   interface{}
 }
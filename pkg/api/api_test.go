@@ -2,16 +2,22 @@ package api_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"testing/fstest"
 	"time"
 
 	"path/filepath"
 
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/logger"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
 	. "github.com/onsi/ginkgo"
@@ -121,7 +127,7 @@ var _ = Describe("Api", func() {
 		err = testhelpers.PollForUp(port)
 		Expect(err).ToNot(HaveOccurred())
 
-		resp, err := http.Post("http://localhost:"+port+"/some-endpoint/222?test=value", "application/json", bytes.NewReader([]byte(`{
+		resp, err := testhelpers.AuthorizedRequest(http.MethodPost, "http://localhost:"+port+"/some-endpoint/222?test=value", "some-token", bytes.NewReader([]byte(`{
 			"sent": 2
 		}`)))
 		Expect(err).ToNot(HaveOccurred())
@@ -137,6 +143,44 @@ var _ = Describe("Api", func() {
 		}`))
 	})
 
+	It("logs requests to the structured logger by default", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		logs := testhelpers.NewFakeBuffer()
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Logger:    logger.New("api", logs, logger.INFO),
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+"/v1/info", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("X-Request-Id", "some-request-id")
+
+		_, err = http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		var line map[string]interface{}
+		Eventually(func() []string { return logs.GetContent() }).Should(ContainElement(ContainSubstring("api.request")))
+		for _, l := range logs.GetContent() {
+			if strings.Contains(l, "api.request") {
+				Expect(json.Unmarshal([]byte(l), &line)).To(Succeed())
+			}
+		}
+
+		Expect(line["data"]).To(HaveKeyWithValue("request_id", "some-request-id"))
+		Expect(line["data"]).To(HaveKeyWithValue("route", "/v1/info"))
+		Expect(line["data"]).To(HaveKeyWithValue("method", "GET"))
+		Expect(line["data"]).To(HaveKeyWithValue("status", float64(200)))
+	})
+
 	It("stops the server", func() {
 		port, err := testhelpers.GetOpenPort()
 		Expect(err).ToNot(HaveOccurred())
@@ -159,6 +203,84 @@ var _ = Describe("Api", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	Context("Run", func() {
+		It("returns an error if the port cannot be bound", func() {
+			l, err := net.Listen("tcp", "localhost:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer l.Close()
+
+			_, port, err := net.SplitHostPort(l.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+
+			server := api.New(api.Config{
+				UAAClient: testhelpers.NewFakeUAAClient(),
+				Port:      port,
+			})
+
+			err = server.Run(context.Background())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("waits for in-flight requests to finish and calls the shutdown callbacks", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			started := make(chan interface{}, 1)
+			release := make(chan interface{})
+			shutdownCalled := make(chan interface{}, 1)
+
+			server := api.New(api.Config{
+				UAAClient:       testhelpers.NewFakeUAAClient(),
+				Port:            port,
+				ShutdownTimeout: 5 * time.Second,
+				OnShutdown: []func(){
+					func() { shutdownCalled <- struct{}{} },
+				},
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/slow-endpoint",
+						Auth:   auth.None,
+						Handle: func(r api.Request) *api.Response {
+							started <- struct{}{}
+							<-release
+
+							return api.NoContent()
+						},
+					},
+				},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- server.Run(ctx)
+			}()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			statusCodes := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				resp, err := http.Get("http://localhost:" + port + "/slow-endpoint")
+				Expect(err).ToNot(HaveOccurred())
+				statusCodes <- resp.StatusCode
+			}()
+
+			Eventually(started).Should(Receive())
+			cancel()
+
+			Consistently(runErr).ShouldNot(Receive())
+			close(release)
+
+			Eventually(statusCodes).Should(Receive(Equal(http.StatusNoContent)))
+			Eventually(runErr).Should(Receive(BeNil()))
+			Expect(shutdownCalled).To(Receive())
+		})
+	})
+
 	Context("Auth", func() {
 		It("requires user to be logged in with logged in auth type", func() {
 			port, err := testhelpers.GetOpenPort()
@@ -198,7 +320,7 @@ var _ = Describe("Api", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			By("having no error returned by uaa")
-			resp, err := http.Get("http://localhost:" + port + "/auth-endpoint")
+			resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(resp.StatusCode).To(Equal(http.StatusOK))
 			Eventually(called).Should(Receive())
@@ -207,7 +329,7 @@ var _ = Describe("Api", func() {
 			By("having an error returned by uaa")
 			uaaClient.SetUser(nil)
 
-			resp, err = http.Get("http://localhost:" + port + "/auth-endpoint")
+			resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
 			Consistently(called).ShouldNot(Receive())
@@ -266,6 +388,145 @@ var _ = Describe("Api", func() {
 			Expect(resp.StatusCode).To(Equal(http.StatusOK))
 			Eventually(called).Should(Receive())
 			Eventually(logRequestCalled).Should(Receive())
+
+			By("not checking the token with uaa")
+			resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/no-auth-endpoint", "some-token", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(uaaClient.CallCount()).To(Equal(0))
+		})
+
+		It("does not check with uaa when there is no bearer token", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			uaaClient := testhelpers.NewFakeUAAClient()
+			uaaClient.SetUser(&uaaclient.User{ID: "some-id"})
+
+			server := api.New(api.Config{
+				UAAClient: uaaClient,
+				Port:      port,
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/auth-endpoint",
+						Auth:   auth.LoggedIn,
+						Handle: func(r api.Request) *api.Response {
+							return api.Ok(nil)
+						},
+					},
+				},
+			})
+
+			stop := server.Start()
+			defer stop()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := http.Get("http://localhost:" + port + "/auth-endpoint")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(uaaClient.CallCount()).To(Equal(0))
+
+			resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(uaaClient.CallCount()).To(Equal(1))
+			Expect(uaaClient.LastToken()).To(Equal("some-token"))
+		})
+
+		It("runs the endpoint authorizer after authentication", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			uaaClient := testhelpers.NewFakeUAAClient()
+			uaaClient.SetUser(&uaaclient.User{ID: "some-id"})
+
+			called := make(chan interface{}, 10)
+			server := api.New(api.Config{
+				UAAClient: uaaClient,
+				Port:      port,
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/users/{userID}/preferences",
+						Auth:   auth.LoggedIn,
+						Authorize: func(r api.Request, user *uaaclient.User) (bool, string) {
+							return r.GetParam("userID") == user.ID, "users may only read their own preferences"
+						},
+						Handle: func(r api.Request) *api.Response {
+							called <- struct{}{}
+
+							return api.Ok(nil)
+						},
+					},
+				},
+			})
+
+			stop := server.Start()
+			defer stop()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("requesting the user's own resource")
+			resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/users/some-id/preferences", "some-token", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Eventually(called).Should(Receive())
+
+			By("requesting another user's resource")
+			resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/users/other-id/preferences", "some-token", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			Consistently(called).ShouldNot(Receive())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(MatchJSON(`{"errors": [{"description": "users may only read their own preferences"}]}`))
+
+			By("not being authenticated")
+			resp, err = http.Get("http://localhost:" + port + "/users/some-id/preferences")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Consistently(called).ShouldNot(Receive())
+		})
+
+		It("gives handlers the context of the http request", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			contexts := make(chan context.Context, 1)
+			server := api.New(api.Config{
+				UAAClient: testhelpers.NewFakeUAAClient(),
+				Port:      port,
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/context-endpoint",
+						Auth:   auth.None,
+						Handle: func(r api.Request) *api.Response {
+							contexts <- r.Context()
+
+							return api.Ok(nil)
+						},
+					},
+				},
+			})
+
+			stop := server.Start()
+			defer stop()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = http.Get("http://localhost:" + port + "/context-endpoint")
+			Expect(err).ToNot(HaveOccurred())
+
+			var ctx context.Context
+			Eventually(contexts).Should(Receive(&ctx))
+			Eventually(ctx.Done()).Should(BeClosed())
 		})
 	})
 
@@ -276,9 +537,9 @@ var _ = Describe("Api", func() {
 		uaaClient := testhelpers.NewFakeUAAClient()
 		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"some-scope"}})
 
-		logRequestCalled := make(chan interface{}, 10)
-		fakeLogRequest := func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {
-			logRequestCalled <- struct{}{}
+		logRequestCalled := make(chan api.Response, 10)
+		fakeLogRequest := func(req api.Request, resp api.Response, endpoint *api.Endpoint, startTime time.Time, totalTime time.Duration) {
+			logRequestCalled <- resp
 		}
 
 		called := make(chan interface{}, 10)
@@ -307,7 +568,7 @@ var _ = Describe("Api", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		By("having no error returned by uaa and the correct scopes")
-		resp, err := http.Get("http://localhost:" + port + "/auth-endpoint")
+		resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(resp.StatusCode).To(Equal(http.StatusOK))
 		Eventually(called).Should(Receive())
@@ -316,32 +577,98 @@ var _ = Describe("Api", func() {
 		By("having no error returned by uaa and the incorrect scopes")
 		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"bad-scope"}})
 
-		resp, err = http.Get("http://localhost:" + port + "/auth-endpoint")
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal(`Bearer error="insufficient_scope", scope="some-scope"`))
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "token requires one of the scopes: some-scope"}]}`))
 		Consistently(called).ShouldNot(Receive())
 		Eventually(logRequestCalled).Should(Receive())
 
 		By("having no error returned by uaa and no scopes")
 		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{}})
 
-		resp, err = http.Get("http://localhost:" + port + "/auth-endpoint")
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
 		Consistently(called).ShouldNot(Receive())
 		Eventually(logRequestCalled).Should(Receive())
 
 		By("having an error returned by uaa")
 		uaaClient.SetError(errors.New("uaa error"))
 
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/auth-endpoint", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal(`Bearer error="invalid_token"`))
+		Consistently(called).ShouldNot(Receive())
+
+		var loggedResp api.Response
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp.Err).To(MatchError("uaa error"))
+
+		By("having no bearer token")
 		resp, err = http.Get("http://localhost:" + port + "/auth-endpoint")
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal("Bearer"))
 		Consistently(called).ShouldNot(Receive())
 		Eventually(logRequestCalled).Should(Receive())
 	})
 
+	It("only names scopes the user could get in the insufficient scope challenge", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		uaaClient := testhelpers.NewFakeUAAClient()
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"banned"}})
+
+		ok := func(r api.Request) *api.Response { return api.Ok(nil) }
+		server := api.New(api.Config{
+			UAAClient:  uaaClient,
+			Port:       port,
+			LogRequest: func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+			Endpoints: []*api.Endpoint{
+				{Method: http.MethodGet, Path: "/not", Auth: auth.Not(auth.AnyScope("banned")), Handle: ok},
+				{Method: http.MethodGet, Path: "/pattern", Auth: auth.AnyScope("notifications.*", "admin"), Handle: ok},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/not", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal(`Bearer error="insufficient_scope"`))
+
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/pattern", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal(`Bearer error="insufficient_scope", scope="admin"`))
+	})
+
+	It("refuses endpoints with an auth config it cannot evaluate", func() {
+		Expect(func() {
+			api.New(api.Config{
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/broken",
+						Auth:   &auth.Config{AuthType: auth.AND},
+						Handle: func(r api.Request) *api.Response { return api.Ok(nil) },
+					},
+				},
+			})
+		}).To(PanicWith("endpoint GET /broken: AND needs at least one config"))
+	})
+
 	Context("HTMLTemplate Responses", func() {
 		It("renders html templates with a hostname", func() {
 			port, err := testhelpers.GetOpenPort()
@@ -387,6 +714,58 @@ var _ = Describe("Api", func() {
 			Expect(string(body)).To(ContainSubstring("the hostname is example.com"))
 		})
 
+		It("renders handler data safely inside a layout", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			templatesDir, err := ioutil.TempDir("", "templates")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(templatesDir)
+
+			err = os.Mkdir(filepath.Join(templatesDir, "layouts"), 0755)
+			Expect(err).ToNot(HaveOccurred())
+			err = ioutil.WriteFile(filepath.Join(templatesDir, "layouts", "main.html"), []byte(`<main>{{template "content" .}}</main>`), 0644)
+			Expect(err).ToNot(HaveOccurred())
+			err = ioutil.WriteFile(filepath.Join(templatesDir, "unsubscribe.html"), []byte(`{{define "content"}}Bye {{.Email}} from {{hostname}}{{end}}`), 0644)
+			Expect(err).ToNot(HaveOccurred())
+
+			server := api.New(api.Config{
+				UAAClient:          testhelpers.NewFakeUAAClient(),
+				Hostname:           "example.com",
+				TemplatesDirectory: templatesDir,
+				Port:               port,
+				LogRequest:         func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/unsubscribe",
+						Auth:   auth.None,
+						Handle: func(r api.Request) *api.Response {
+							return api.Ok(api.HTMLTemplate{
+								Name:   "unsubscribe",
+								Layout: "main",
+								Data:   map[string]string{"Email": r.GetParam("email")},
+							})
+						},
+					},
+				},
+			})
+
+			stop := server.Start()
+			defer stop()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := http.Get("http://localhost:" + port + "/unsubscribe?email=%3Cb%3Eann%3C/b%3E")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("<main>Bye &lt;b&gt;ann&lt;/b&gt; from example.com</main>"))
+		})
+
 		It("returns a server error if the template doesn't exist", func() {
 			port, err := testhelpers.GetOpenPort()
 			Expect(err).ToNot(HaveOccurred())
@@ -461,5 +840,56 @@ var _ = Describe("Api", func() {
 			Expect(body).ToNot(BeNil())
 			Expect(resp.Header.Get("Content-Type")).To(Equal("image/png"))
 		})
+
+		It("serves assets and templates from an fs.FS", func() {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			files := fstest.MapFS{
+				"style.css":  {Data: []byte("body {}")},
+				"page.html":  {Data: []byte("<p>{{.}}</p>")},
+				"secret.txt": {Data: []byte("secret")},
+			}
+
+			server := api.New(api.Config{
+				UAAClient:   testhelpers.NewFakeUAAClient(),
+				AssetsFS:    files,
+				TemplatesFS: files,
+				Port:        port,
+				LogRequest:  func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+				Endpoints: []*api.Endpoint{
+					{
+						Method: http.MethodGet,
+						Path:   "/page",
+						Auth:   auth.None,
+						Handle: func(r api.Request) *api.Response {
+							return api.Ok(api.HTMLTemplate{Name: r.GetParam("name"), Data: "embedded"})
+						},
+					},
+				},
+			})
+
+			stop := server.Start()
+			defer stop()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := http.Get("http://localhost:" + port + "/assets/style.css")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("text/css; charset=utf-8"))
+
+			resp, err = http.Get("http://localhost:" + port + "/page?name=page")
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("<p>embedded</p>"))
+
+			resp, err = http.Get("http://localhost:" + port + "/page?name=../secret")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		})
 	})
 })
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAssetsCacheControl = "no-cache"
+	fingerprintCacheControl   = "public, max-age=31536000, immutable"
+	fingerprintLength         = 16
+)
+
+var fingerprintPattern = regexp.MustCompile(`^(.*)\.([0-9a-f]{` + strconv.Itoa(fingerprintLength) + `})(\.[^./]*)?$`)
+
+// precompressedSuffixes maps the encodings assets may have variants for to
+// the suffix of the variant's file name.
+var precompressedSuffixes = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+}
+
+// assetServer serves the files under /assets/. Each file is hashed the first
+// time it is served or linked to, and the hash is used for its ETag and its
+// fingerprinted URL, which can be cached forever.
+type assetServer struct {
+	files        fs.FS
+	cacheControl string
+
+	mu     sync.Mutex
+	hashes map[string]assetHash
+}
+
+type assetHash struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+func newAssetServer(files fs.FS, cacheControl string) *assetServer {
+	if cacheControl == "" {
+		cacheControl = defaultAssetsCacheControl
+	}
+
+	return &assetServer{
+		files:        files,
+		cacheControl: cacheControl,
+		hashes:       map[string]assetHash{},
+	}
+}
+
+// URL returns the fingerprinted URL of the asset called name, such as
+// /assets/css/app.0123456789abcdef.css for css/app.css. Templates get it as
+// the asset func. The plain URL is returned if the file cannot be read.
+func (a *assetServer) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	hash, err := a.hash(name)
+	if err != nil {
+		return "/assets/" + name
+	}
+
+	ext := path.Ext(name)
+	return "/assets/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func (a *assetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/assets/")
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	cacheControl := a.cacheControl
+	hash, err := a.hash(name)
+	if err != nil {
+		original, requested, ok := parseFingerprint(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		hash, err = a.hash(original)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// an outdated fingerprint still gets the current file, but it must
+		// not be cached as if it was the requested version
+		if hash == requested {
+			cacheControl = fingerprintCacheControl
+		}
+		name = original
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	addVary(w.Header(), "Accept-Encoding")
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	for _, encoding := range acceptedEncodings(r, compressionEncodings) {
+		served := a.serveFile(w, r, name+precompressedSuffixes[encoding], `"`+hash+"-"+encoding+`"`, func() {
+			w.Header().Set("Content-Encoding", encoding)
+		})
+		if served {
+			return
+		}
+	}
+
+	if !a.serveFile(w, r, name, `"`+hash+`"`, func() {}) {
+		http.NotFound(w, r)
+	}
+}
+
+// serveFile serves the file called name if it exists, handling conditional
+// and range requests.
+func (a *assetServer) serveFile(w http.ResponseWriter, r *http.Request, name, etag string, setHeaders func()) bool {
+	f, err := a.files.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	setHeaders()
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, info.ModTime(), content)
+
+	return true
+}
+
+// hash returns the fingerprint of the file called name, hashing it again only
+// if it has changed since the last time.
+func (a *assetServer) hash(name string) (string, error) {
+	info, err := fs.Stat(a.files, name)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fs.ErrNotExist
+	}
+
+	a.mu.Lock()
+	cached, ok := a.hashes[name]
+	a.mu.Unlock()
+
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.hash, nil
+	}
+
+	f, err := a.files.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))[:fingerprintLength]
+
+	a.mu.Lock()
+	a.hashes[name] = assetHash{modTime: info.ModTime(), size: info.Size(), hash: hash}
+	a.mu.Unlock()
+
+	return hash, nil
+}
+
+// parseFingerprint splits a fingerprinted name such as
+// css/app.0123456789abcdef.css into css/app.css and the fingerprint.
+func parseFingerprint(name string) (original string, fingerprint string, ok bool) {
+	m := fingerprintPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+
+	return m[1] + m[3], m[2], true
+}
+
+// acceptedEncodings returns the encodings out of offered that the
+// Accept-Encoding header of r allows, the client's favourite first and ties in
+// the order offered. A * rates every encoding the header does not name.
+// Encodings rated below identity are left out when the header rates identity,
+// since the client would rather have the response uncompressed.
+func acceptedEncodings(r *http.Request, offered []string) []string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				q, err = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+			}
+		}
+
+		qualities[name] = q
+	}
+
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+
+	identity := quality("identity")
+	var accepted []string
+	for _, encoding := range offered {
+		q := quality(encoding)
+		if q > 0 && q >= identity {
+			accepted = append(accepted, encoding)
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return quality(accepted[i]) > quality(accepted[j])
+	})
+
+	return accepted
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing/fstest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Assets", func() {
+	It("serves assets for templates to link to", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			AssetsFS: fstest.MapFS{
+				"css/app.css":    {Data: []byte("body {}"), ModTime: modTime},
+				"css/app.css.gz": {Data: []byte("gzipped"), ModTime: modTime},
+				"css/app.css.br": {Data: []byte("brotli"), ModTime: modTime},
+				"js/app.js":      {Data: []byte("alert()"), ModTime: modTime},
+			},
+			TemplatesFS: fstest.MapFS{"page.html": {Data: []byte(`<link href="{{asset "css/app.css"}}">`)}},
+			LogRequest:  func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/page",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.Ok(api.HTMLTemplate{Name: "page"})
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		get := func(path string, header http.Header) (*http.Response, string) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			for k, v := range header {
+				req.Header[k] = v
+			}
+
+			resp, err := client.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp, string(body)
+		}
+
+		By("serving nested assets with validators")
+		resp, body := get("/assets/js/app.js", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("alert()"))
+		Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/javascript"))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("no-cache"))
+		Expect(resp.Header.Get("Last-Modified")).To(Equal("Thu, 02 Jan 2020 03:04:05 GMT"))
+		Expect(resp.Header.Get("ETag")).To(MatchRegexp(`^"[0-9a-f]{16}"$`))
+
+		resp, _ = get("/assets/js/app.js", http.Header{"If-None-Match": {resp.Header.Get("ETag")}})
+		Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+
+		resp, _ = get("/assets/js/app.js", http.Header{"If-Modified-Since": {"Thu, 02 Jan 2020 03:04:05 GMT"}})
+		Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+
+		By("not serving directories or missing files")
+		resp, _ = get("/assets/css/", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+		resp, _ = get("/assets/css/missing.css", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+		By("linking templates to fingerprinted URLs that can be cached forever")
+		_, page := get("/page", nil)
+		matches := regexp.MustCompile(`href="(/assets/css/app\.[0-9a-f]{16}\.css)"`).FindStringSubmatch(page)
+		Expect(matches).To(HaveLen(2))
+
+		resp, body = get(matches[1], nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("body {}"))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/css; charset=utf-8"))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("public, max-age=31536000, immutable"))
+
+		By("serving outdated fingerprints without caching them")
+		resp, body = get("/assets/css/app.0123456789abcdef.css", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("body {}"))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("no-cache"))
+
+		By("serving precompressed variants the client accepts")
+		resp, body = get("/assets/css/app.css", http.Header{"Accept-Encoding": {"gzip, br"}})
+		Expect(body).To(Equal("brotli"))
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/css; charset=utf-8"))
+		Expect(resp.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+		brotliETag := resp.Header.Get("ETag")
+
+		resp, body = get("/assets/css/app.css", http.Header{"Accept-Encoding": {"br;q=0, gzip"}})
+		Expect(body).To(Equal("gzipped"))
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(resp.Header.Get("ETag")).ToNot(Equal(brotliETag))
+
+		resp, body = get("/assets/css/app.css", http.Header{"Accept-Encoding": {"br;q=0.5, gzip"}})
+		Expect(body).To(Equal("gzipped"))
+
+		resp, body = get("/assets/css/app.css", nil)
+		Expect(body).To(Equal("body {}"))
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+
+		resp, body = get("/assets/js/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+		Expect(body).To(Equal("alert()"))
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Assets Cache-Control", func() {
+	It("is configurable", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient:          testhelpers.NewFakeUAAClient(),
+			Port:               port,
+			AssetsFS:           fstest.MapFS{"app.js": {Data: []byte("alert()")}},
+			AssetsCacheControl: "public, max-age=600",
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := http.Get("http://localhost:" + port + "/assets/app.js")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("public, max-age=600"))
+	})
+})
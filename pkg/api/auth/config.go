@@ -1,8 +1,19 @@
 package auth
 
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Config describes who may call an endpoint. Scopes may contain wildcard
+// patterns such as "notification_preferences.*". AND, OR and NOT combine the
+// child Configs, so requirements can be built up as expression trees.
 type Config struct {
 	AuthType AuthType
 	Scopes   []string
+	Configs  []*Config
 }
 
 type AuthType int
@@ -11,6 +22,10 @@ const (
 	NONE AuthType = iota
 	LOGGEDIN
 	SCOPE
+	ALLSCOPES
+	AND
+	OR
+	NOT
 )
 
 func AnyScope(scopes ...string) *Config {
@@ -20,6 +35,57 @@ func AnyScope(scopes ...string) *Config {
 	}
 }
 
+func AllScopes(scopes ...string) *Config {
+	return &Config{
+		AuthType: ALLSCOPES,
+		Scopes:   scopes,
+	}
+}
+
+// And passes when every config passes. It panics without configs, since an
+// empty And would let anyone through.
+func And(configs ...*Config) *Config {
+	mustHaveConfigs("And", configs)
+
+	return &Config{
+		AuthType: AND,
+		Configs:  configs,
+	}
+}
+
+// Or passes when any config passes. It panics without configs.
+func Or(configs ...*Config) *Config {
+	mustHaveConfigs("Or", configs)
+
+	return &Config{
+		AuthType: OR,
+		Configs:  configs,
+	}
+}
+
+// Not passes for logged in users who do not satisfy config. It panics if
+// config is nil.
+func Not(config *Config) *Config {
+	mustHaveConfigs("Not", []*Config{config})
+
+	return &Config{
+		AuthType: NOT,
+		Configs:  []*Config{config},
+	}
+}
+
+func mustHaveConfigs(name string, configs []*Config) {
+	if len(configs) == 0 {
+		panic("auth: " + name + " needs at least one config")
+	}
+
+	for _, config := range configs {
+		if config == nil {
+			panic("auth: " + name + " was given a nil config")
+		}
+	}
+}
+
 var LoggedIn = &Config{
 	AuthType: LOGGEDIN,
 	Scopes:   []string{},
@@ -28,3 +94,172 @@ var LoggedIn = &Config{
 var None = &Config{
 	AuthType: NONE,
 }
+
+// Allows reports whether a user with the given scopes passes the config.
+// Anonymous requests are checked with loggedIn set to false.
+func (c *Config) Allows(loggedIn bool, scopes []string) bool {
+	switch c.AuthType {
+	case NONE:
+		return true
+
+	case LOGGEDIN:
+		return loggedIn
+
+	case SCOPE:
+		if !loggedIn {
+			return false
+		}
+
+		// an empty scope list only requires the user to be logged in
+		if len(c.Scopes) == 0 {
+			return true
+		}
+
+		for _, pattern := range c.Scopes {
+			if hasScope(pattern, scopes) {
+				return true
+			}
+		}
+
+		return false
+
+	case ALLSCOPES:
+		if !loggedIn {
+			return false
+		}
+
+		for _, pattern := range c.Scopes {
+			if !hasScope(pattern, scopes) {
+				return false
+			}
+		}
+
+		return true
+
+	case AND:
+		for _, config := range c.Configs {
+			if !config.Allows(loggedIn, scopes) {
+				return false
+			}
+		}
+
+		return true
+
+	case OR:
+		for _, config := range c.Configs {
+			if config.Allows(loggedIn, scopes) {
+				return true
+			}
+		}
+
+		return false
+
+	case NOT:
+		return loggedIn && !c.Configs[0].Allows(loggedIn, scopes)
+	}
+
+	return false
+}
+
+// Validate reports configs that cannot be evaluated, such as an AND without
+// any children, which the constructors would have refused.
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("auth config is nil")
+	}
+
+	switch c.AuthType {
+	case NONE, LOGGEDIN, SCOPE, ALLSCOPES:
+		return nil
+	case AND, OR:
+		if len(c.Configs) == 0 {
+			return fmt.Errorf("%s needs at least one config", c.typeName())
+		}
+	case NOT:
+		if len(c.Configs) != 1 {
+			return errors.New("NOT needs exactly one config")
+		}
+	default:
+		return fmt.Errorf("unknown auth type %d", c.AuthType)
+	}
+
+	for _, config := range c.Configs {
+		err := config.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) typeName() string {
+	if c.AuthType == AND {
+		return "AND"
+	}
+
+	return "OR"
+}
+
+// ScopeNames lists the scopes that can help to pass the config. Scopes under a
+// NOT are left out, since holding them only ever hurts, and so are patterns,
+// which are not the names of scopes.
+func (c *Config) ScopeNames() []string {
+	if c.AuthType == NOT {
+		return nil
+	}
+
+	var names []string
+	for _, scope := range c.Scopes {
+		if !strings.ContainsAny(scope, `*?[\`) {
+			names = append(names, scope)
+		}
+	}
+
+	for _, config := range c.Configs {
+		names = append(names, config.ScopeNames()...)
+	}
+
+	return names
+}
+
+func (c *Config) String() string {
+	switch c.AuthType {
+	case NONE:
+		return "no authentication"
+	case LOGGEDIN:
+		return "a logged in user"
+	case SCOPE:
+		return "one of the scopes: " + strings.Join(c.Scopes, ", ")
+	case ALLSCOPES:
+		return "all of the scopes: " + strings.Join(c.Scopes, ", ")
+	case AND:
+		return join(c.Configs, " and ")
+	case OR:
+		return join(c.Configs, " or ")
+	case NOT:
+		return "not " + join(c.Configs, "")
+	}
+
+	return "unknown auth type"
+}
+
+func join(configs []*Config, sep string) string {
+	parts := make([]string, 0, len(configs))
+	for _, config := range configs {
+		parts = append(parts, "("+config.String()+")")
+	}
+
+	return strings.Join(parts, sep)
+}
+
+func hasScope(pattern string, scopes []string) bool {
+	for _, scope := range scopes {
+		matched, err := path.Match(pattern, scope)
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
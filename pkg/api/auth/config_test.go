@@ -0,0 +1,102 @@
+package auth_test
+
+import (
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	Describe("Allows", func() {
+		It("allows anyone with none", func() {
+			Expect(auth.None.Allows(false, nil)).To(BeTrue())
+		})
+
+		It("requires a logged in user with logged in", func() {
+			Expect(auth.LoggedIn.Allows(false, nil)).To(BeFalse())
+			Expect(auth.LoggedIn.Allows(true, nil)).To(BeTrue())
+		})
+
+		It("requires any of the scopes with any scope", func() {
+			config := auth.AnyScope("scim.read", "cloud_controller.admin")
+
+			Expect(config.Allows(true, []string{"scim.read"})).To(BeTrue())
+			Expect(config.Allows(true, []string{"openid"})).To(BeFalse())
+			Expect(config.Allows(false, []string{"scim.read"})).To(BeFalse())
+		})
+
+		It("requires every scope with all scopes", func() {
+			config := auth.AllScopes("scim.read", "cloud_controller.admin")
+
+			Expect(config.Allows(true, []string{"scim.read", "cloud_controller.admin"})).To(BeTrue())
+			Expect(config.Allows(true, []string{"scim.read"})).To(BeFalse())
+			Expect(config.Allows(false, []string{"scim.read", "cloud_controller.admin"})).To(BeFalse())
+		})
+
+		It("matches wildcard scopes", func() {
+			config := auth.AnyScope("notification_preferences.*")
+
+			Expect(config.Allows(true, []string{"notification_preferences.read"})).To(BeTrue())
+			Expect(config.Allows(true, []string{"notifications.write"})).To(BeFalse())
+		})
+
+		It("combines configs with and, or and not", func() {
+			config := auth.Or(
+				auth.AnyScope("cloud_controller.admin"),
+				auth.And(
+					auth.AnyScope("notifications.*"),
+					auth.Not(auth.AnyScope("notifications.banned")),
+				),
+			)
+
+			Expect(config.Allows(true, []string{"cloud_controller.admin"})).To(BeTrue())
+			Expect(config.Allows(true, []string{"notifications.write"})).To(BeTrue())
+			Expect(config.Allows(true, []string{"notifications.write", "notifications.banned"})).To(BeFalse())
+			Expect(config.Allows(true, []string{"openid"})).To(BeFalse())
+			Expect(config.Allows(false, nil)).To(BeFalse())
+		})
+
+		It("allows anonymous requests when an or includes none", func() {
+			config := auth.Or(auth.None, auth.AnyScope("scim.read"))
+
+			Expect(config.Allows(false, nil)).To(BeTrue())
+		})
+	})
+
+	It("describes the config", func() {
+		config := auth.And(auth.AllScopes("scim.read", "scim.write"), auth.Not(auth.AnyScope("banned")))
+
+		Expect(config.String()).To(Equal("(all of the scopes: scim.read, scim.write) and (not (one of the scopes: banned))"))
+		Expect(config.ScopeNames()).To(Equal([]string{"scim.read", "scim.write"}))
+	})
+
+	It("leaves patterns out of the scope names", func() {
+		config := auth.Or(auth.AnyScope("notifications.*", "notifications.admin"), auth.AllScopes("scim.?"))
+
+		Expect(config.ScopeNames()).To(Equal([]string{"notifications.admin"}))
+		Expect(auth.Not(auth.AnyScope("banned")).ScopeNames()).To(BeEmpty())
+	})
+
+	It("panics when combinators are given nothing to combine", func() {
+		Expect(func() { auth.And() }).To(PanicWith("auth: And needs at least one config"))
+		Expect(func() { auth.Or() }).To(PanicWith("auth: Or needs at least one config"))
+		Expect(func() { auth.Or(auth.None, nil) }).To(PanicWith("auth: Or was given a nil config"))
+		Expect(func() { auth.Not(nil) }).To(PanicWith("auth: Not was given a nil config"))
+	})
+
+	Describe("Validate", func() {
+		It("accepts configs built by the constructors", func() {
+			config := auth.Or(auth.LoggedIn, auth.And(auth.AnyScope("a"), auth.Not(auth.AllScopes("b"))))
+
+			Expect(config.Validate()).To(Succeed())
+		})
+
+		It("rejects configs that cannot be evaluated", func() {
+			var nilConfig *auth.Config
+			Expect(nilConfig.Validate()).To(MatchError("auth config is nil"))
+			Expect((&auth.Config{AuthType: auth.AND}).Validate()).To(MatchError("AND needs at least one config"))
+			Expect((&auth.Config{AuthType: auth.NOT}).Validate()).To(MatchError("NOT needs exactly one config"))
+			Expect((&auth.Config{AuthType: auth.OR, Configs: []*auth.Config{nil}}).Validate()).To(MatchError("auth config is nil"))
+		})
+	})
+})
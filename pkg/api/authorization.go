@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+)
+
+// checkAuth returns the response to send instead of calling the endpoint, or
+// nil if the request may go ahead. The token is only checked when the config
+// does not already allow anonymous requests. A missing or invalid token is a
+// 401 and a valid token without the required scopes is a 403, as in RFC 6750.
+// Requests that pass are then handed to the endpoint's Authorizer.
+func (s *Server) checkAuth(endpoint *Endpoint, req *realRequest) *Response {
+	resp := s.checkAuthConfig(endpoint.Auth, req)
+	if resp != nil || endpoint.Authorize == nil {
+		return resp
+	}
+
+	allowed, reason := endpoint.Authorize(req, req.CurrentUser())
+	if !allowed {
+		if reason == "" {
+			reason = http.StatusText(http.StatusForbidden)
+		}
+
+		return Forbidden(errors.New(reason))
+	}
+
+	return nil
+}
+
+func (s *Server) checkAuthConfig(authConfig *auth.Config, req *realRequest) *Response {
+	if authConfig.Allows(false, nil) {
+		return nil
+	}
+
+	currentUser, err := req.checkToken()
+	if err == errNoBearerToken {
+		return bearerChallenge(http.StatusUnauthorized, err, "Bearer")
+	}
+
+	if err != nil || currentUser == nil {
+		return bearerChallenge(http.StatusUnauthorized, err, `Bearer error="invalid_token"`)
+	}
+
+	if authConfig.Allows(true, currentUser.Scopes) {
+		return nil
+	}
+
+	resp := Forbidden(fmt.Errorf("token requires %s", authConfig))
+	resp.Header = http.Header{}
+	challenge := `Bearer error="insufficient_scope"`
+	scopes := authConfig.ScopeNames()
+	if len(scopes) > 0 {
+		challenge += fmt.Sprintf(`, scope="%s"`, strings.Join(scopes, " "))
+	}
+	resp.Header.Set("WWW-Authenticate", challenge)
+
+	return resp
+}
+
+// guard only lets requests that pass authConfig through to handler.
+func (s *Server) guard(authConfig *auth.Config, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &realRequest{
+			httpRequest: r,
+			uaaClient:   s.uaaClient,
+		}
+
+		resp := s.checkAuthConfig(authConfig, req)
+		if resp != nil {
+			s.writeResponse(w, r, *resp)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func bearerChallenge(status int, err error, challenge string) *Response {
+	resp := &Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Err:        err,
+	}
+	resp.Header.Set("WWW-Authenticate", challenge)
+
+	return resp
+}
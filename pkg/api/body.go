@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type bodyTooLargeError struct {
+	limit int64
+}
+
+func errBodyTooLarge(limit int64) error {
+	return bodyTooLargeError{limit: limit}
+}
+
+func (e bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body must not be larger than %d bytes", e.limit)
+}
+
+// checkBody answers requests whose body the endpoint will not accept, going by
+// the headers alone. Bodies sent without a Content-Length are only found to be
+// too large once they are read.
+func checkBody(endpoint *Endpoint, req *realRequest) *Response {
+	r := req.httpRequest
+	if !hasBody(r) {
+		return nil
+	}
+
+	if len(endpoint.ContentTypes) > 0 {
+		contentType := r.Header.Get("Content-Type")
+		if !acceptsContentType(endpoint.ContentTypes, contentType) {
+			return UnsupportedMediaType(fmt.Errorf("Content-Type must be one of: %s", strings.Join(endpoint.ContentTypes, ", ")))
+		}
+	}
+
+	if req.maxBodyBytes > 0 && r.ContentLength > req.maxBodyBytes {
+		return RequestEntityTooLarge(errBodyTooLarge(req.maxBodyBytes))
+	}
+
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+func acceptsContentType(accepted []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, a := range accepted {
+		if strings.EqualFold(a, mediaType) {
+			return true
+		}
+	}
+
+	return false
+}
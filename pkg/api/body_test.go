@@ -0,0 +1,183 @@
+package api_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request bodies", func() {
+	echo := func(r api.Request) *api.Response {
+		var body map[string]string
+		err := r.Decode(&body)
+		if err != nil {
+			return api.BadRequest(err)
+		}
+
+		return api.Ok(body)
+	}
+
+	It("limits the size and content type of request bodies", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient:    testhelpers.NewFakeUAAClient(),
+			Port:         port,
+			MaxBodyBytes: 16,
+			Endpoints: []*api.Endpoint{
+				{
+					Method:       http.MethodPost,
+					Path:         "/small",
+					Auth:         auth.None,
+					ContentTypes: []string{"application/json"},
+					Handle:       echo,
+				},
+				{
+					Method:       http.MethodPost,
+					Path:         "/large",
+					Auth:         auth.None,
+					MaxBodyBytes: 1024,
+					Handle:       echo,
+				},
+				{
+					Method:       http.MethodGet,
+					Path:         "/no-body",
+					Auth:         auth.None,
+					ContentTypes: []string{"application/json"},
+					Handle: func(r api.Request) *api.Response {
+						return api.NoContent()
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		post := func(path, contentType string, body io.Reader) *http.Response {
+			resp, err := http.Post("http://localhost:"+port+path, contentType, body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp
+		}
+
+		By("accepting bodies within the limit")
+		resp := post("/small", "application/json; charset=utf-8", strings.NewReader(`{"a": "b"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		By("rejecting a declared Content-Length over the limit")
+		resp = post("/small", "application/json", strings.NewReader(`{"a": "bcdefghijklmnop"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "request body must not be larger than 16 bytes"}]}`))
+
+		By("rejecting a chunked body once it grows over the limit")
+		// a reader without a known length is sent chunked
+		resp = post("/small", "application/json", ioutil.NopCloser(strings.NewReader(`{"a": "bcdefghijklmnop"}`)))
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+
+		By("letting endpoints override the limit")
+		resp = post("/large", "application/json", strings.NewReader(`{"a": "bcdefghijklmnop"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		By("rejecting content types the endpoint does not accept")
+		resp = post("/small", "text/plain", strings.NewReader(`{"a": "b"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+
+		body, err = ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "Content-Type must be one of: application/json"}]}`))
+
+		resp = post("/large", "text/plain", strings.NewReader(`{"a": "b"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		By("not checking the content type of requests without a body")
+		resp, err = http.Get("http://localhost:" + port + "/no-body")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+		By("returning read errors from Decode")
+		conn, err := net.Dial("tcp", "localhost:"+port)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "POST /large HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"a\"")
+		Expect(err).ToNot(HaveOccurred())
+		conn.(*net.TCPConn).CloseWrite()
+
+		resp, err = http.ReadResponse(bufio.NewReader(conn), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+		body, err = ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("reading request body: unexpected EOF"))
+	})
+
+	It("validates decoded bodies", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodPost,
+					Path:   "/validated",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						var body struct {
+							Name string `json:"name" validate:"required,max=3"`
+						}
+						resp := r.DecodeAndValidate(&body)
+						if resp != nil {
+							return resp
+						}
+
+						return api.Ok(body)
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		post := func(body string) *http.Response {
+			resp, err := http.Post("http://localhost:"+port+"/validated", "application/json", strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp
+		}
+
+		resp := post(`{"name": "abc"}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		resp = post(`{"name": ""}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "name is required", "field": "name", "code": "required"}]}`))
+
+		resp = post(`{`)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const defaultCompressionMinSize = 1024
+
+// compressionEncodings are the encodings responses and assets can be sent
+// with. Brotli comes first, so it wins when the client rates both the same.
+var compressionEncodings = []string{"br", "gzip"}
+
+// CompressionConfig turns on gzip and brotli compression of responses for
+// clients that accept it. Bodies smaller than MinSize, which defaults to 1KiB,
+// are sent as they are, since compressing them saves little. Streamed bodies
+// are compressed as soon as they are flushed, whatever their size.
+//
+// ExcludedContentTypes replaces DefaultExcludedContentTypes. An entry such as
+// "video/*" excludes a whole type. Responses that already have a
+// Content-Encoding, such as precompressed assets, are never compressed again.
+type CompressionConfig struct {
+	MinSize              int
+	ExcludedContentTypes []string
+}
+
+// DefaultExcludedContentTypes are formats that are compressed already.
+var DefaultExcludedContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/pdf",
+}
+
+func compress(config CompressionConfig, next http.Handler) http.Handler {
+	if config.MinSize == 0 {
+		config.MinSize = defaultCompressionMinSize
+	}
+
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = DefaultExcludedContentTypes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := ""
+		if r.Method != http.MethodHead {
+			accepted := acceptedEncodings(r, compressionEncodings)
+			if len(accepted) > 0 {
+				encoding = accepted[0]
+			}
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			config:         config,
+			encoding:       encoding,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the start of the body until it knows whether the
+// response is worth compressing. Without an encoding it only adds the Vary
+// header.
+type compressWriter struct {
+	http.ResponseWriter
+	config   CompressionConfig
+	encoding string
+
+	status      int
+	wroteHeader bool
+	buffer      []byte
+	decided     bool
+	encoder     io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = status
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		return w.body().Write(b)
+	}
+
+	w.buffer = append(w.buffer, b...)
+	if w.encoding == "" || len(w.buffer) >= w.config.MinSize {
+		err := w.start(true)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close sends whatever is still held back and finishes the compressed stream.
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.start(len(w.buffer) >= w.config.MinSize)
+	}
+
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
+
+func (w *compressWriter) body() io.Writer {
+	if w.encoder != nil {
+		return w.encoder
+	}
+
+	return w.ResponseWriter
+}
+
+// start decides whether to compress, sends the header and writes the body held
+// back so far.
+func (w *compressWriter) start(bigEnough bool) error {
+	w.decided = true
+	header := w.Header()
+	addVary(header, "Accept-Encoding")
+
+	if header.Get("Content-Type") == "" && len(w.buffer) > 0 {
+		// net/http would sniff it too, but only after we have compressed it
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+
+	if bigEnough && w.compressible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+
+		// the compressed body is a different representation of the resource
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buffer) == 0 {
+		return nil
+	}
+
+	_, err := w.body().Write(w.buffer)
+	w.buffer = nil
+
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+
+	switch {
+	case w.encoding == "",
+		w.status < http.StatusOK,
+		w.status == http.StatusNoContent,
+		w.status == http.StatusNotModified,
+		w.status == http.StatusPartialContent,
+		header.Get("Content-Encoding") != "",
+		header.Get("Content-Range") != "":
+		return false
+	}
+
+	contentLength, err := strconv.Atoi(header.Get("Content-Length"))
+	if err == nil && contentLength < w.config.MinSize {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	for _, excluded := range w.config.ExcludedContentTypes {
+		if excluded == mediaType || strings.HasSuffix(excluded, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(excluded, "*")) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "br" {
+		return brotli.NewWriter(w)
+	}
+
+	return gzip.NewWriter(w)
+}
+
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, existing := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), value) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", value)
+}
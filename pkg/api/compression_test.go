@@ -0,0 +1,196 @@
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	acceptedEncodings := func(acceptEncoding string) []string {
+		r := httptest.NewRequest(http.MethodGet, "/large", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+
+		return api.AcceptedEncodings(r, []string{"br", "gzip"})
+	}
+
+	It("orders the accepted encodings by quality", func() {
+		for acceptEncoding, encodings := range map[string][]string{
+			"gzip":                                {"gzip"},
+			"gzip, br":                            {"br", "gzip"},
+			"gzip;q=1, br;q=0.5":                  {"gzip", "br"},
+			"br;q=0.8, gzip;q=0.8":                {"br", "gzip"},
+			"*":                                   {"br", "gzip"},
+			"gzip;q=0.9, *;q=0.5":                 {"gzip", "br"},
+			"br;q=0, *":                           {"gzip"},
+			"deflate, gzip;q=0.2, identity;q=0.1": {"gzip"},
+		} {
+			Expect(acceptedEncodings(acceptEncoding)).To(Equal(encodings), acceptEncoding)
+		}
+	})
+
+	It("accepts nothing that is worse than identity", func() {
+		for _, acceptEncoding := range []string{"", "*;q=0", "gzip;q=0.5, identity", "gzip;q=0, identity", "gzip;q=nope"} {
+			Expect(acceptedEncodings(acceptEncoding)).To(BeEmpty(), acceptEncoding)
+		}
+	})
+
+	It("compresses responses for clients that accept it", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		large := strings.Repeat("a", 2048)
+		endpoint := func(path string, body interface{}) *api.Endpoint {
+			return &api.Endpoint{
+				Method: http.MethodGet,
+				Path:   path,
+				Auth:   auth.None,
+				Handle: func(r api.Request) *api.Response {
+					return api.Ok(body)
+				},
+			}
+		}
+
+		server := api.New(api.Config{
+			UAAClient:   testhelpers.NewFakeUAAClient(),
+			Port:        port,
+			Compression: &api.CompressionConfig{},
+			LogRequest:  func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+			AssetsFS: fstest.MapFS{
+				"app.js":        {Data: []byte(large)},
+				"app.css":       {Data: []byte(large)},
+				"app.css.br":    {Data: []byte("brotli")},
+				"image.png":     {Data: []byte(large)},
+				"small-file.js": {Data: []byte("alert()")},
+			},
+			TemplatesFS: fstest.MapFS{"page.html": {Data: []byte("<p>{{.}}</p>")}},
+			Endpoints: []*api.Endpoint{
+				endpoint("/large", []string{large}),
+				endpoint("/small", []string{"a"}),
+				endpoint("/page", api.HTMLTemplate{Name: "page", Data: large}),
+				{
+					Method: http.MethodGet,
+					Path:   "/ndjson",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						items := make(chan string, 2)
+						items <- "a"
+						items <- "b"
+						close(items)
+
+						return api.Ok(api.NDJSONFromChannel(items))
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		get := func(path, acceptEncoding string) (*http.Response, []byte) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+
+			resp, err := client.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp, body
+		}
+
+		decode := func(encoding string, body []byte) string {
+			var r io.Reader
+			switch encoding {
+			case "gzip":
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				r = gz
+			case "br":
+				r = brotli.NewReader(bytes.NewReader(body))
+			default:
+				r = bytes.NewReader(body)
+			}
+
+			b, err := ioutil.ReadAll(r)
+			Expect(err).ToNot(HaveOccurred())
+
+			return string(b)
+		}
+
+		By("compressing JSON bodies with the encoding the client prefers")
+		resp, body := get("/large", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(resp.Header.Values("Vary")).To(ConsistOf("Accept-Encoding", "Accept"))
+		Expect(len(body)).To(BeNumerically("<", len(large)))
+		Expect(decode("gzip", body)).To(MatchJSON(`["` + large + `"]`))
+
+		resp, body = get("/large", "gzip, br")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+		Expect(decode("br", body)).To(MatchJSON(`["` + large + `"]`))
+
+		By("not compressing for clients that do not accept it")
+		resp, body = get("/large", "")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(resp.Header.Values("Vary")).To(ConsistOf("Accept-Encoding", "Accept"))
+		Expect(body).To(MatchJSON(`["` + large + `"]`))
+
+		By("not compressing small bodies")
+		resp, body = get("/small", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(resp.Header.Values("Vary")).To(ContainElement("Accept-Encoding"))
+		Expect(body).To(MatchJSON(`["a"]`))
+
+		resp, _ = get("/assets/small-file.js", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+
+		By("compressing templates")
+		resp, body = get("/page", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/html"))
+		Expect(decode("gzip", body)).To(Equal("<p>" + large + "</p>"))
+
+		By("compressing streams")
+		resp, body = get("/ndjson", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(decode("gzip", body)).To(Equal("\"a\"\n\"b\"\n"))
+
+		By("compressing assets, weakening their ETag")
+		resp, body = get("/assets/app.js", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(resp.Header.Get("ETag")).To(HavePrefix(`W/"`))
+		Expect(resp.Header.Values("Vary")).To(ConsistOf("Accept-Encoding"))
+		Expect(decode("gzip", body)).To(Equal(large))
+
+		By("leaving precompressed assets and excluded types alone")
+		resp, body = get("/assets/app.css", "br")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+		Expect(string(body)).To(Equal("brotli"))
+
+		resp, body = get("/assets/image.png", "gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(string(body)).To(Equal(large))
+	})
+})
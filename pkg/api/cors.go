@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lets browsers call the API from other origins. AllowedOrigins
+// holds origins such as "https://admin.example.com", patterns such as
+// "https://*.example.com" or "*" for any origin. AllowedMethods defaults to
+// the methods of the endpoints on the requested path and AllowedHeaders to
+// Authorization and Content-Type, and "*" allows any header. MaxAge tells the
+// browser how long it may cache the answer to a preflight request.
+//
+// Credentials cannot be allowed for "*", since that would let any site make
+// requests with the user's cookies; New panics on such a config.
+//
+// Preflight OPTIONS requests are answered by the server for every path with an
+// endpoint that has a CORSConfig, either its own or the one from Config.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var defaultCORSHeaders = []string{"Authorization", "Content-Type"}
+
+func (c *CORSConfig) validate() error {
+	if c != nil && c.AllowCredentials && containsFold(c.AllowedOrigins, "*") {
+		return errors.New(`CORS cannot allow credentials for any origin ("*")`)
+	}
+
+	return nil
+}
+
+// allowOrigin returns the value for Access-Control-Allow-Origin, or false if
+// origin may not make requests.
+func (c *CORSConfig) allowOrigin(origin string) (string, bool) {
+	if origin == "" {
+		return "", false
+	}
+
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+
+		matched, err := path.Match(strings.ToLower(allowed), strings.ToLower(origin))
+		if err == nil && matched {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *CORSConfig) allowsHeaders(requested string) bool {
+	allowed := c.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = defaultCORSHeaders
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		if !containsFold(allowed, header) && !containsFold(allowed, "*") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeCORSHeaders adds the headers for an actual, not preflight, request.
+func writeCORSHeaders(config *CORSConfig, w http.ResponseWriter, r *http.Request) {
+	if config == nil {
+		return
+	}
+
+	header := w.Header()
+	addVary(header, "Origin")
+
+	allowOrigin, ok := config.allowOrigin(r.Header.Get("Origin"))
+	if !ok {
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(config.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+	}
+}
+
+// preflight answers OPTIONS requests to a path, using the CORS configuration
+// of the endpoint for the method the browser asks about.
+func (s *Server) preflight(endpoints []*Endpoint) http.HandlerFunc {
+	methods := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		methods = append(methods, e.Method)
+	}
+	methods = append(methods, http.MethodOptions)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Allow", strings.Join(methods, ", "))
+		addVary(header, "Origin")
+		addVary(header, "Access-Control-Request-Method")
+		addVary(header, "Access-Control-Request-Headers")
+
+		s.allowPreflight(header, endpoints, methods, r)
+
+		// without the Access-Control-Allow headers the browser fails the
+		// request, so a refused preflight needs no error status
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) allowPreflight(header http.Header, endpoints []*Endpoint, methods []string, r *http.Request) {
+	method := r.Header.Get("Access-Control-Request-Method")
+	config := s.corsConfigFor(endpoints, method)
+	if config == nil {
+		return
+	}
+
+	allowOrigin, ok := config.allowOrigin(r.Header.Get("Origin"))
+	if !ok {
+		return
+	}
+
+	allowedMethods := config.AllowedMethods
+	if len(allowedMethods) == 0 {
+		allowedMethods = methods
+	}
+	if !containsFold(allowedMethods, method) {
+		return
+	}
+
+	requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+	if !config.allowsHeaders(requestedHeaders) {
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+	if requestedHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+	if config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+	}
+}
+
+// needsPreflight reports whether the server should answer OPTIONS requests to
+// the path the endpoints share. It leaves them to an endpoint for OPTIONS.
+func (s *Server) needsPreflight(endpoints []*Endpoint) bool {
+	withCORS := false
+	for _, e := range endpoints {
+		if e.Method == http.MethodOptions {
+			return false
+		}
+
+		if s.endpointCORS(e) != nil {
+			withCORS = true
+		}
+	}
+
+	return withCORS
+}
+
+func (s *Server) corsConfigFor(endpoints []*Endpoint, method string) *CORSConfig {
+	for _, e := range endpoints {
+		if strings.EqualFold(e.Method, method) {
+			return s.endpointCORS(e)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) endpointCORS(endpoint *Endpoint) *CORSConfig {
+	if endpoint.CORS != nil {
+		return endpoint.CORS
+	}
+
+	return s.cors
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,230 @@
+package api_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CORS", func() {
+	ok := func(r api.Request) *api.Response {
+		return api.Ok(nil)
+	}
+
+	It("allows listed origins and origin patterns", func() {
+		config := &api.CORSConfig{AllowedOrigins: []string{"https://admin.example.com", "https://*.apps.example.com"}}
+
+		for origin, allowed := range map[string]bool{
+			"https://admin.example.com":         true,
+			"HTTPS://Admin.Example.com":         true,
+			"https://ui.apps.example.com":       true,
+			"https://apps.example.com.evil.com": false,
+			"https://ui.apps.example.com.evil":  false,
+			"https://evil.com":                  false,
+			"":                                  false,
+		} {
+			allowOrigin, ok := config.AllowOrigin(origin)
+			Expect(ok).To(Equal(allowed), origin)
+			if allowed {
+				Expect(allowOrigin).To(Equal(origin), origin)
+			}
+		}
+	})
+
+	It("allows any origin with *", func() {
+		config := &api.CORSConfig{AllowedOrigins: []string{"*"}}
+
+		allowOrigin, ok := config.AllowOrigin("https://anywhere.com")
+		Expect(ok).To(BeTrue())
+		Expect(allowOrigin).To(Equal("*"))
+	})
+
+	It("allows the default headers unless told otherwise", func() {
+		config := &api.CORSConfig{}
+		Expect(config.AllowsHeaders("")).To(BeTrue())
+		Expect(config.AllowsHeaders("authorization, Content-Type")).To(BeTrue())
+		Expect(config.AllowsHeaders("authorization, X-Custom")).To(BeFalse())
+
+		config = &api.CORSConfig{AllowedHeaders: []string{"X-Custom"}}
+		Expect(config.AllowsHeaders("x-custom")).To(BeTrue())
+		Expect(config.AllowsHeaders("authorization")).To(BeFalse())
+
+		config = &api.CORSConfig{AllowedHeaders: []string{"*"}}
+		Expect(config.AllowsHeaders("X-Custom, X-Other")).To(BeTrue())
+	})
+
+	It("answers cross-origin requests", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		uaaClient := testhelpers.NewFakeUAAClient()
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id"})
+
+		server := api.New(api.Config{
+			UAAClient:  uaaClient,
+			Port:       port,
+			LogRequest: func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+			CORS: &api.CORSConfig{
+				AllowedOrigins:   []string{"https://admin.example.com", "https://*.apps.example.com"},
+				ExposedHeaders:   []string{"X-Request-Id"},
+				AllowCredentials: true,
+				MaxAge:           10 * time.Minute,
+			},
+			Endpoints: []*api.Endpoint{
+				{Method: http.MethodGet, Path: "/preferences", Auth: auth.LoggedIn, Handle: ok},
+				{Method: http.MethodPut, Path: "/preferences", Auth: auth.LoggedIn, Handle: ok},
+				{
+					Method: http.MethodGet,
+					Path:   "/public",
+					Auth:   auth.None,
+					CORS: &api.CORSConfig{
+						AllowedOrigins: []string{"*"},
+						AllowedHeaders: []string{"*"},
+					},
+					Handle: ok,
+				},
+				{
+					Method: http.MethodOptions,
+					Path:   "/custom",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return &api.Response{StatusCode: http.StatusTeapot}
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		request := func(method, path string, header http.Header) *http.Response {
+			req, err := http.NewRequest(method, "http://localhost:"+port+path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header = header
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp
+		}
+
+		preflight := func(path, origin, method, headers string) *http.Response {
+			return request(http.MethodOptions, path, http.Header{
+				"Origin":                         {origin},
+				"Access-Control-Request-Method":  {method},
+				"Access-Control-Request-Headers": {headers},
+			})
+		}
+
+		By("answering preflight requests from allowed origins")
+		resp := preflight("/preferences", "https://admin.example.com", "PUT", "authorization, content-type")
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://admin.example.com"))
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("GET, PUT, OPTIONS"))
+		Expect(resp.Header.Get("Access-Control-Allow-Headers")).To(Equal("authorization, content-type"))
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+		Expect(resp.Header.Get("Access-Control-Max-Age")).To(Equal("600"))
+		Expect(resp.Header.Values("Vary")).To(ContainElement("Origin"))
+
+		By("refusing preflight requests from other origins")
+		resp = preflight("/preferences", "https://evil.com", "GET", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		By("refusing preflight requests for a method without an endpoint")
+		resp = preflight("/preferences", "https://admin.example.com", "DELETE", "")
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		By("refusing preflight requests for other headers")
+		resp = preflight("/preferences", "https://admin.example.com", "GET", "X-Custom")
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		By("adding CORS headers to actual requests")
+		resp = request(http.MethodGet, "/preferences", http.Header{
+			"Origin":        {"https://admin.example.com"},
+			"Authorization": {"Bearer some-token"},
+		})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://admin.example.com"))
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+		Expect(resp.Header.Get("Access-Control-Expose-Headers")).To(Equal("X-Request-Id"))
+
+		resp = request(http.MethodGet, "/preferences", http.Header{
+			"Origin":        {"https://evil.com"},
+			"Authorization": {"Bearer some-token"},
+		})
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		By("answering unauthenticated requests too, so the browser can read the error")
+		resp = request(http.MethodGet, "/preferences", http.Header{"Origin": {"https://admin.example.com"}})
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://admin.example.com"))
+
+		By("letting endpoints override the configuration")
+		resp = preflight("/public", "https://anywhere.com", "GET", "X-Custom")
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		Expect(resp.Header.Get("Access-Control-Allow-Headers")).To(Equal("X-Custom"))
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).To(BeEmpty())
+		Expect(resp.Header.Get("Access-Control-Max-Age")).To(BeEmpty())
+
+		By("leaving OPTIONS to endpoints that handle it")
+		resp = preflight("/custom", "https://admin.example.com", "OPTIONS", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusTeapot))
+	})
+
+	It("refuses to allow credentials for any origin", func() {
+		anyOrigin := &api.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+
+		Expect(func() {
+			api.New(api.Config{CORS: anyOrigin})
+		}).To(PanicWith(`CORS cannot allow credentials for any origin ("*")`))
+
+		Expect(func() {
+			api.New(api.Config{
+				Endpoints: []*api.Endpoint{
+					{Method: http.MethodGet, Path: "/public", Auth: auth.None, CORS: anyOrigin, Handle: ok},
+				},
+			})
+		}).To(PanicWith(`endpoint GET /public: CORS cannot allow credentials for any origin ("*")`))
+	})
+})
+
+var _ = Describe("Without CORS", func() {
+	It("does not answer OPTIONS requests", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient:  testhelpers.NewFakeUAAClient(),
+			Port:       port,
+			LogRequest: func(api.Request, api.Response, *api.Endpoint, time.Time, time.Duration) {},
+			Endpoints: []*api.Endpoint{
+				{Method: http.MethodGet, Path: "/preferences", Auth: auth.None, Handle: func(r api.Request) *api.Response {
+					return api.Ok(nil)
+				}},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := http.NewRequest(http.MethodOptions, "http://localhost:"+port+"/preferences", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Origin", "https://admin.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/gorilla/mux"
+	"github.com/rcrowley/go-metrics"
+	"github.com/rcrowley/go-metrics/exp"
+)
+
+// DebugConfig controls the debug routes: the go-metrics dump at
+// /debug/metrics and the pprof handlers under /debug/pprof/. Setting Port
+// serves them on a separate admin listener instead of the main one, and Auth
+// guards them wherever they are served. Without a DebugConfig only
+// /debug/metrics is served, unauthenticated, on the main port. The pprof
+// handlers expose the command line and heap, so on the main port they are only
+// served when Auth keeps anonymous requests out.
+type DebugConfig struct {
+	Disabled bool
+	Port     string
+	Auth     *auth.Config
+}
+
+func (s *Server) registerDebug(router *mux.Router, config *DebugConfig) {
+	metricsHandler := exp.ExpHandler(metrics.DefaultRegistry)
+
+	if config == nil {
+		router.Handle("/debug/metrics", metricsHandler).Methods(http.MethodGet)
+		return
+	}
+
+	if config.Disabled {
+		return
+	}
+
+	authConfig := config.Auth
+	if authConfig == nil {
+		authConfig = auth.None
+	}
+
+	if config.Port != "" {
+		router = mux.NewRouter()
+		s.adminServer = &http.Server{
+			Addr:    net.JoinHostPort("", config.Port),
+			Handler: router,
+		}
+	}
+
+	router.Handle("/debug/metrics", s.guard(authConfig, metricsHandler)).Methods(http.MethodGet)
+	if config.Port == "" && authConfig.Allows(false, nil) {
+		return
+	}
+
+	router.Handle("/debug/pprof/cmdline", s.guard(authConfig, http.HandlerFunc(pprof.Cmdline))).Methods(http.MethodGet)
+	router.Handle("/debug/pprof/profile", s.guard(authConfig, http.HandlerFunc(pprof.Profile))).Methods(http.MethodGet)
+	router.Handle("/debug/pprof/symbol", s.guard(authConfig, http.HandlerFunc(pprof.Symbol))).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/debug/pprof/trace", s.guard(authConfig, http.HandlerFunc(pprof.Trace))).Methods(http.MethodGet)
+	// Index also serves the named profiles such as /debug/pprof/heap
+	router.PathPrefix("/debug/pprof/").Handler(s.guard(authConfig, http.HandlerFunc(pprof.Index))).Methods(http.MethodGet)
+}
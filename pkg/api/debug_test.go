@@ -0,0 +1,124 @@
+package api_test
+
+import (
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Debug routes", func() {
+	get := func(port, path string) int {
+		resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+path, "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		return resp.StatusCode
+	}
+
+	It("can be disabled", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Debug:     &api.DebugConfig{Disabled: true},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(get(port, "/debug/metrics")).To(Equal(http.StatusNotFound))
+		Expect(get(port, "/debug/pprof/")).To(Equal(http.StatusNotFound))
+	})
+
+	It("does not serve pprof publicly on the main port", func() {
+		for _, debugConfig := range []*api.DebugConfig{nil, {}, {Auth: auth.Or(auth.None, auth.AnyScope("admin"))}} {
+			port, err := testhelpers.GetOpenPort()
+			Expect(err).ToNot(HaveOccurred())
+
+			server := api.New(api.Config{
+				UAAClient: testhelpers.NewFakeUAAClient(),
+				Port:      port,
+				Debug:     debugConfig,
+			})
+			stop := server.Start()
+
+			err = testhelpers.PollForUp(port)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(get(port, "/debug/metrics")).To(Equal(http.StatusOK))
+			Expect(get(port, "/debug/pprof/")).To(Equal(http.StatusNotFound))
+			Expect(get(port, "/debug/pprof/cmdline")).To(Equal(http.StatusNotFound))
+			Expect(get(port, "/debug/pprof/heap")).To(Equal(http.StatusNotFound))
+
+			stop()
+		}
+	})
+
+	It("guards the metrics and pprof routes with auth", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		uaaClient := testhelpers.NewFakeUAAClient()
+		server := api.New(api.Config{
+			UAAClient: uaaClient,
+			Port:      port,
+			Debug:     &api.DebugConfig{Auth: auth.AnyScope("admin")},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("refusing users without the scope")
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"openid"}})
+		Expect(get(port, "/debug/metrics")).To(Equal(http.StatusForbidden))
+		Expect(get(port, "/debug/pprof/")).To(Equal(http.StatusForbidden))
+		Expect(get(port, "/debug/pprof/heap")).To(Equal(http.StatusForbidden))
+
+		By("serving users with the scope")
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"admin"}})
+		Expect(get(port, "/debug/metrics")).To(Equal(http.StatusOK))
+		Expect(get(port, "/debug/pprof/")).To(Equal(http.StatusOK))
+		Expect(get(port, "/debug/pprof/heap")).To(Equal(http.StatusOK))
+		Expect(get(port, "/debug/pprof/cmdline")).To(Equal(http.StatusOK))
+	})
+
+	It("serves the debug routes on a separate admin port", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		adminPort, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Debug:     &api.DebugConfig{Port: adminPort},
+		})
+		stop := server.Start()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = testhelpers.PollForUp(adminPort)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(get(port, "/debug/metrics")).To(Equal(http.StatusNotFound))
+		Expect(get(adminPort, "/debug/metrics")).To(Equal(http.StatusOK))
+		Expect(get(adminPort, "/debug/pprof/goroutine")).To(Equal(http.StatusOK))
+
+		stop()
+
+		_, err = http.Get("http://localhost:" + adminPort + "/debug/metrics")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DecodeOptions turns on strict decoding of JSON request bodies for an
+// endpoint. Without any of them Decode behaves like json.Unmarshal. Anything
+// after the first JSON value is always rejected with CodeTrailingData.
+type DecodeOptions struct {
+	// DisallowUnknownFields rejects keys that do not match a field of the
+	// target struct, such as misspellings.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into interface{} values as json.Number rather
+	// than float64, so large integers keep their precision.
+	UseNumber bool
+}
+
+// Codes set on the ErrorResponse of a body that could not be decoded.
+const (
+	CodeUnknownField = "unknown_field"
+	CodeInvalidType  = "invalid_type"
+	CodeTrailingData = "trailing_data"
+)
+
+// fieldError is a decode failure caused by one field of the body. BadRequest
+// sends its Field and Code along with the description.
+type fieldError struct {
+	field string
+	code  string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+func decodeJSON(body []byte, target interface{}, options DecodeOptions) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if options.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if options.UseNumber {
+		decoder.UseNumber()
+	}
+
+	err := decoder.Decode(target)
+	if err == io.EOF {
+		// the message json.Unmarshal gives for an empty body
+		return errors.New("unexpected end of JSON input")
+	}
+	if err != nil {
+		return describeDecodeError(err)
+	}
+
+	_, err = decoder.Token()
+	if err != io.EOF {
+		return &fieldError{
+			code: CodeTrailingData,
+			err:  errors.New("request body must contain a single JSON value"),
+		}
+	}
+
+	return nil
+}
+
+func describeDecodeError(err error) error {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+		return &fieldError{
+			field: typeErr.Field,
+			code:  CodeInvalidType,
+			err:   fmt.Errorf("%s must be of type %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value),
+		}
+	}
+
+	// encoding/json has no error type for unknown fields
+	const unknownField = "json: unknown field "
+	if msg := err.Error(); strings.HasPrefix(msg, unknownField) {
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(msg, unknownField))
+		if unquoteErr == nil {
+			return &fieldError{
+				field: field,
+				code:  CodeUnknownField,
+				err:   fmt.Errorf("%s is not a known field", field),
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,135 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/errors"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding", func() {
+	type Pet struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	type Owner struct {
+		Pet   Pet         `json:"pet"`
+		Extra interface{} `json:"extra"`
+	}
+
+	strict := api.DecodeOptions{DisallowUnknownFields: true, UseNumber: true}
+
+	// errorBody is what BadRequest sends for err
+	errorBody := func(err error) errors.ErrorListResponse {
+		return api.BadRequest(err).Body.(errors.ErrorListResponse)
+	}
+
+	It("ignores unknown fields by default", func() {
+		var owner Owner
+		err := api.DecodeJSON([]byte(`{"pet": {"nmae": "Rex"}}`), &owner, api.DecodeOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects unknown fields, naming them", func() {
+		var owner Owner
+		err := api.DecodeJSON([]byte(`{"pet": {"nmae": "Rex"}}`), &owner, strict)
+		Expect(errorBody(err).Errors).To(Equal([]errors.ErrorResponse{
+			{Description: "nmae is not a known field", Field: "nmae", Code: api.CodeUnknownField},
+		}))
+	})
+
+	It("names fields of the wrong type", func() {
+		var owner Owner
+		err := api.DecodeJSON([]byte(`{"pet": {"age": "three"}}`), &owner, strict)
+		Expect(errorBody(err).Errors).To(Equal([]errors.ErrorResponse{
+			{Description: "pet.age must be of type int, got string", Field: "pet.age", Code: api.CodeInvalidType},
+		}))
+	})
+
+	It("rejects trailing data whatever the options", func() {
+		for _, options := range []api.DecodeOptions{{}, {DisallowUnknownFields: true}, strict} {
+			for _, body := range []string{`{"pet": {}} {"pet": {}}`, `{"pet": {}} garbage`} {
+				var owner Owner
+				err := api.DecodeJSON([]byte(body), &owner, options)
+				Expect(errorBody(err).Errors).To(Equal([]errors.ErrorResponse{
+					{Description: "request body must contain a single JSON value", Code: api.CodeTrailingData},
+				}), body)
+			}
+
+			var owner Owner
+			err := api.DecodeJSON([]byte("{\"pet\": {}}\n"), &owner, options)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("rejects empty bodies like json.Unmarshal", func() {
+		var owner Owner
+		err := api.DecodeJSON(nil, &owner, api.DecodeOptions{})
+		Expect(err).To(MatchError("unexpected end of JSON input"))
+	})
+
+	It("can decode numbers as json.Number", func() {
+		var owner Owner
+		err := api.DecodeJSON([]byte(`{"extra": 12345678901234567890}`), &owner, api.DecodeOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(owner.Extra).To(BeAssignableToTypeOf(float64(0)))
+
+		err = api.DecodeJSON([]byte(`{"extra": 12345678901234567890}`), &owner, strict)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(owner.Extra).To(Equal(json.Number("12345678901234567890")))
+	})
+
+	It("decodes request bodies with the endpoint's options", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		decode := func(r api.Request) *api.Response {
+			var owner Owner
+			err := r.Decode(&owner)
+			if err != nil {
+				return api.BadRequest(err)
+			}
+
+			return api.Ok(nil)
+		}
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{
+				{Method: http.MethodPost, Path: "/lenient", Auth: auth.None, Handle: decode},
+				{Method: http.MethodPost, Path: "/strict", Auth: auth.None, DecodeOptions: strict, Handle: decode},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		post := func(path, body string) (int, string) {
+			resp, err := http.Post("http://localhost:"+port+path, "application/json", strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+
+			b, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp.StatusCode, string(b)
+		}
+
+		status, _ := post("/lenient", `{"pet": {"nmae": "Rex"}}`)
+		Expect(status).To(Equal(http.StatusOK))
+
+		status, body := post("/strict", `{"pet": {"nmae": "Rex"}}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "nmae is not a known field", "field": "nmae", "code": "unknown_field"}]}`))
+	})
+})
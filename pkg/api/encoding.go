@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Encoder turns a response body into bytes of one media type.
+type Encoder func(body interface{}) ([]byte, error)
+
+const jsonMediaType = "application/json"
+
+// DefaultEncoders are the encoders every server has. Config.Encoders can add
+// to them or replace them.
+func DefaultEncoders() map[string]Encoder {
+	return map[string]Encoder{
+		jsonMediaType:        EncodeJSON,
+		"application/yaml":   EncodeYAML,
+		"application/x-yaml": EncodeYAML,
+		"text/plain":         EncodeText,
+	}
+}
+
+func EncodeJSON(body interface{}) ([]byte, error) {
+	return json.Marshal(body)
+}
+
+// EncodePrettyJSON is used instead of the JSON encoder when a request has the
+// pretty query parameter.
+func EncodePrettyJSON(body interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// EncodeYAML goes through JSON first so the keys match the json struct tags
+// clients already know from the JSON responses.
+func EncodeYAML(body interface{}) ([]byte, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	err = yaml.Unmarshal(b, &generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(generic)
+}
+
+// EncodeText writes strings, byte slices, errors and fmt.Stringers as they
+// are and error lists one description per line. Anything else is written as
+// indented JSON, so fields hidden from the JSON responses stay hidden.
+func EncodeText(body interface{}) ([]byte, error) {
+	var s string
+
+	switch b := body.(type) {
+	case string:
+		s = b
+	case []byte:
+		return b, nil
+	case errors.ErrorListResponse:
+		s = strings.Join(b.GetErrors(), "\n")
+	case error:
+		s = b.Error()
+	case fmt.Stringer:
+		s = b.String()
+	default:
+		return EncodePrettyJSON(body)
+	}
+
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+
+	return []byte(s), nil
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+	position  int
+}
+
+// negotiate picks the registered media type the client prefers, going by the
+// Accept header. JSON is picked when there is no Accept header, and ok is
+// false when nothing registered is acceptable.
+func negotiate(r *http.Request, encoders map[string]Encoder) (mediaType string, ok bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jsonMediaType, true
+	}
+
+	ranges := parseAccept(accept)
+
+	var candidates []mediaRange
+	for registered := range encoders {
+		match, found := bestMatch(registered, ranges)
+		if found && match.q > 0 {
+			candidates = append(candidates, mediaRange{mediaType: registered, q: match.q, position: match.position})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.q != b.q {
+			return a.q > b.q
+		}
+		if a.position != b.position {
+			return a.position < b.position
+		}
+		// a wildcard matched both, so fall back to JSON before anything else
+		if (a.mediaType == jsonMediaType) != (b.mediaType == jsonMediaType) {
+			return a.mediaType == jsonMediaType
+		}
+		return a.mediaType < b.mediaType
+	})
+
+	return candidates[0].mediaType, true
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q, position: i})
+	}
+
+	return ranges
+}
+
+// bestMatch finds the most specific range matching mediaType, since that is
+// the one whose quality applies.
+func bestMatch(mediaType string, ranges []mediaRange) (mediaRange, bool) {
+	var (
+		best        mediaRange
+		specificity = -1
+	)
+
+	major := strings.SplitN(mediaType, "/", 2)[0]
+	for _, r := range ranges {
+		s := -1
+		switch r.mediaType {
+		case mediaType:
+			s = 2
+		case major + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			best, specificity = r, s
+		}
+	}
+
+	return best, specificity >= 0
+}
+
+func wantsPretty(r *http.Request) bool {
+	values, ok := r.URL.Query()["pretty"]
+	if !ok {
+		return false
+	}
+
+	pretty, err := strconv.ParseBool(values[0])
+	return err != nil || pretty
+}
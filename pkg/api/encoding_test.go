@@ -0,0 +1,202 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	apierrors "github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/errors"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Content negotiation", func() {
+	type Pet struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	negotiate := func(accept string) (string, bool) {
+		r := httptest.NewRequest(http.MethodGet, "/pet", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+
+		encoders := api.DefaultEncoders()
+		encoders["text/csv"] = api.EncodeText
+		delete(encoders, "application/x-yaml")
+
+		return api.Negotiate(r, encoders)
+	}
+
+	It("picks JSON without an Accept header", func() {
+		mediaType, ok := negotiate("")
+		Expect(ok).To(BeTrue())
+		Expect(mediaType).To(Equal("application/json"))
+	})
+
+	It("honours quality values and wildcards", func() {
+		for accept, expected := range map[string]string{
+			"text/csv":                               "text/csv",
+			"application/yaml;q=0.5, text/plain":     "text/plain",
+			"text/html, */*;q=0.1":                   "application/json",
+			"application/*, application/json;q=0":    "application/yaml",
+			"text/*;q=0.5, text/csv":                 "text/csv",
+			"application/yaml, application/json":     "application/yaml",
+			"*/*":                                    "application/json",
+			"text/plain;q=0.9, application/yaml;q=1": "application/yaml",
+		} {
+			mediaType, ok := negotiate(accept)
+			Expect(ok).To(BeTrue(), accept)
+			Expect(mediaType).To(Equal(expected), accept)
+		}
+	})
+
+	It("fails when nothing registered is acceptable", func() {
+		_, ok := negotiate("application/x-yaml")
+		Expect(ok).To(BeFalse())
+
+		_, ok = negotiate("*/*;q=0")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sends YAML with the JSON field names", func() {
+		b, err := api.EncodeYAML(Pet{Name: "Rex", Age: 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(Equal("age: 3\nname: Rex\n"))
+	})
+
+	It("sends plain text", func() {
+		for body, expected := range map[interface{}]string{
+			"hello":                   "hello\n",
+			"done\n":                  "done\n",
+			errors.New("no such pet"): "no such pet\n",
+			Pet{Name: "Rex", Age: 3}:  "{\n  \"name\": \"Rex\",\n  \"age\": 3\n}\n",
+		} {
+			b, err := api.EncodeText(body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b)).To(Equal(expected))
+		}
+
+		b, err := api.EncodeText(apierrors.ErrorListResponse{Errors: []apierrors.ErrorResponse{
+			{Description: "first"},
+			{Description: "second"},
+		}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(Equal("first\nsecond\n"))
+	})
+
+	It("leaves fields hidden from JSON out of plain text", func() {
+		b, err := api.EncodeText(struct {
+			Name     string `json:"name"`
+			Password string `json:"-"`
+		}{Name: "admin", Password: "hunter2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).ToNot(ContainSubstring("hunter2"))
+	})
+
+	It("refuses to remove the JSON encoder", func() {
+		Expect(func() {
+			api.New(api.Config{Encoders: map[string]api.Encoder{"application/json": nil}})
+		}).To(PanicWith("Encoders cannot remove application/json"))
+	})
+
+	It("encodes responses for the Accept header", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Encoders: map[string]api.Encoder{
+				"text/csv": func(body interface{}) ([]byte, error) {
+					pet := body.(Pet)
+
+					var b strings.Builder
+					w := csv.NewWriter(&b)
+					w.Write([]string{pet.Name, fmt.Sprint(pet.Age)})
+					w.Flush()
+
+					return []byte(b.String()), w.Error()
+				},
+				"application/x-yaml": nil,
+			},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/pet",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.Ok(Pet{Name: "Rex", Age: 3})
+					},
+				},
+				{
+					Method: http.MethodGet,
+					Path:   "/missing",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.NotFound(errors.New("no such pet"))
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		get := func(path, accept string) (*http.Response, string) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp, string(body)
+		}
+
+		By("sending JSON without an Accept header")
+		resp, body := get("/pet", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp.Header.Get("Vary")).To(Equal("Accept"))
+		Expect(body).To(Equal(`{"name":"Rex","age":3}`))
+
+		By("pretty printing JSON on request")
+		_, body = get("/pet?pretty", "application/json")
+		Expect(body).To(Equal("{\n  \"name\": \"Rex\",\n  \"age\": 3\n}\n"))
+
+		_, body = get("/pet?pretty=false", "application/json")
+		Expect(body).To(Equal(`{"name":"Rex","age":3}`))
+
+		By("using configured encoders")
+		resp, body = get("/pet", "text/csv")
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/csv; charset=utf-8"))
+		Expect(body).To(Equal("Rex,3\n"))
+
+		By("returning 406 when nothing acceptable is registered")
+		resp, body = get("/pet", "application/x-yaml")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotAcceptable))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "Accept must allow one of: application/json, application/yaml, text/csv, text/plain"}]}`))
+
+		By("keeping error responses, sent as JSON, when nothing acceptable is registered")
+		resp, body = get("/missing", "image/png")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "no such pet"}]}`))
+	})
+})
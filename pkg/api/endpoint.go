@@ -1,10 +1,30 @@
 package api
 
-import "github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+import (
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+)
 
 type Endpoint struct {
-	Path   string
-	Method string
-	Auth   *auth.Config
+	Path       string
+	Method     string
+	Auth       *auth.Config
+	Authorize  Authorizer
+	Middleware []Middleware
+	// MaxBodyBytes overrides Config.MaxBodyBytes for this endpoint.
+	MaxBodyBytes int64
+	// ContentTypes lists the media types the endpoint accepts, such as
+	// "application/json". Requests with a body of any other type get a 415.
+	ContentTypes []string
+	// DecodeOptions makes Decode stricter about request bodies.
+	DecodeOptions DecodeOptions
+	// CORS overrides Config.CORS for this endpoint.
+	CORS   *CORSConfig
 	Handle func(r Request) *Response
 }
+
+// Authorizer decides whether the user may act on the resource a request
+// refers to, e.g. only their own preferences. It runs after Auth has passed
+// and a denial is answered with a 403 carrying the reason. user is nil if the
+// request is not authenticated.
+type Authorizer func(r Request, user *uaaclient.User) (allowed bool, reason string)
@@ -4,8 +4,12 @@ type ErrorListResponse struct {
 	Errors []ErrorResponse `json:"errors"`
 }
 
+// ErrorResponse describes one error. Field and Code are set for validation
+// errors, naming the offending field and the rule it broke.
 type ErrorResponse struct {
 	Description string `json:"description"`
+	Field       string `json:"field,omitempty"`
+	Code        string `json:"code,omitempty"`
 }
 
 func (e *ErrorListResponse) GetErrors() []string {
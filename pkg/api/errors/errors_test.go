@@ -10,8 +10,8 @@ import (
 var _ = Describe("Errors", func() {
 	It("returns a list of descriptions", func() {
 		errorList := &errors.ErrorListResponse{Errors: []errors.ErrorResponse{
-			{"first error"},
-			{"second error"}},
+			{Description: "first error"},
+			{Description: "second error"}},
 		}
 
 		Expect(errorList.GetErrors()).To(ConsistOf("first error", "second error"))
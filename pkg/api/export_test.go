@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+var (
+	Negotiate         = negotiate
+	AcceptedEncodings = acceptedEncodings
+	DecodeJSON        = decodeJSON
+	WriteReader       = writeReader
+)
+
+func (c *CORSConfig) AllowOrigin(origin string) (string, bool) {
+	return c.allowOrigin(origin)
+}
+
+func (c *CORSConfig) AllowsHeaders(requested string) bool {
+	return c.allowsHeaders(requested)
+}
+
+func (n NDJSON) Write(w http.ResponseWriter) error {
+	return n.write(w)
+}
+
+// SetCertCheckInterval changes how often certificate files are checked for
+// changes and returns a func that restores the old interval.
+func SetCertCheckInterval(interval time.Duration) func() {
+	old := certCheckInterval
+	certCheckInterval = interval
+
+	return func() {
+		certCheckInterval = old
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+// Handler turns a Request into a Response, like Endpoint.Handle.
+type Handler func(r Request) *Response
+
+// Middleware wraps a Handler. It runs once the request has passed the
+// endpoint's auth checks, so r.CurrentUser() is the authenticated user. It
+// can answer the request itself without calling next, or change the Response
+// that next returns.
+type Middleware func(next Handler) Handler
+
+// chain wraps handle in the middleware, so that the first middleware sees the
+// request first.
+func chain(handle Handler, middleware ...[]Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		for j := len(middleware[i]) - 1; j >= 0; j-- {
+			handle = middleware[i][j](handle)
+		}
+	}
+
+	return handle
+}
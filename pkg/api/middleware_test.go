@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Middleware", func() {
+	It("wraps endpoints in the server and endpoint middleware", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		uaaClient := testhelpers.NewFakeUAAClient()
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id"})
+
+		var (
+			mu    sync.Mutex
+			calls []string
+		)
+		record := func(name string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+		}
+
+		decorate := func(next api.Handler) api.Handler {
+			return func(r api.Request) *api.Response {
+				record("server:" + r.CurrentUser().ID)
+
+				resp := next(r)
+				if resp.Header == nil {
+					resp.Header = http.Header{}
+				}
+				resp.Header.Set("X-Decorated", "true")
+
+				return resp
+			}
+		}
+
+		gate := func(next api.Handler) api.Handler {
+			return func(r api.Request) *api.Response {
+				record("endpoint")
+
+				if r.GetParam("blocked") != "" {
+					return api.Forbidden(errors.New("feature disabled"))
+				}
+
+				return next(r)
+			}
+		}
+
+		server := api.New(api.Config{
+			UAAClient:  uaaClient,
+			Port:       port,
+			Middleware: []api.Middleware{decorate},
+			Endpoints: []*api.Endpoint{
+				{
+					Method:     http.MethodGet,
+					Path:       "/some-endpoint",
+					Auth:       auth.LoggedIn,
+					Middleware: []api.Middleware{gate},
+					Handle: func(r api.Request) *api.Response {
+						record("handle")
+
+						return api.Ok(nil)
+					},
+				},
+			},
+		})
+
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("passing through every layer")
+		resp, err := testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/some-endpoint", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("X-Decorated")).To(Equal("true"))
+
+		mu.Lock()
+		Expect(calls).To(Equal([]string{"server:some-id", "endpoint", "handle"}))
+		calls = nil
+		mu.Unlock()
+
+		By("short-circuiting the endpoint")
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/some-endpoint?blocked=true", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.Header.Get("X-Decorated")).To(Equal("true"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "feature disabled"}]}`))
+
+		mu.Lock()
+		Expect(calls).To(Equal([]string{"server:some-id", "endpoint"}))
+		mu.Unlock()
+
+		By("not running for unauthenticated requests")
+		resp, err = http.Get("http://localhost:" + port + "/some-endpoint")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+})
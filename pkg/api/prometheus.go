@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PrometheusConfig turns on per-endpoint request metrics and serves them in
+// the Prometheus text format on Path, which defaults to /metrics. Auth guards
+// the metrics route and defaults to auth.None. Registry defaults to a new
+// registry that also holds the Go runtime and process collectors.
+type PrometheusConfig struct {
+	Path     string
+	Auth     *auth.Config
+	Registry *prometheus.Registry
+}
+
+type requestMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newRequestMetrics(registerer prometheus.Registerer) *requestMetrics {
+	m := &requestMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests served, by route, method and status class.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route, method and status class.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served, by route and method.",
+		}, []string{"route", "method"}),
+	}
+
+	registerer.MustRegister(m.requests, m.duration, m.inFlight)
+
+	return m
+}
+
+// begin records the start of a request to endpoint. The returned func records
+// its end with the status that was sent. A nil *requestMetrics records
+// nothing.
+func (m *requestMetrics) begin(endpoint *Endpoint) func(status int) {
+	if m == nil {
+		return func(int) {}
+	}
+
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(endpoint.Path, endpoint.Method)
+	inFlight.Inc()
+
+	return func(status int) {
+		inFlight.Dec()
+
+		class := fmt.Sprintf("%dxx", status/100)
+		m.requests.WithLabelValues(endpoint.Path, endpoint.Method, class).Inc()
+		m.duration.WithLabelValues(endpoint.Path, endpoint.Method, class).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (s *Server) registerPrometheus(router *mux.Router, prometheusConfig *PrometheusConfig) {
+	config := *prometheusConfig
+	if config.Path == "" {
+		config.Path = "/metrics"
+	}
+
+	if config.Auth == nil {
+		config.Auth = auth.None
+	}
+
+	if config.Registry == nil {
+		config.Registry = prometheus.NewRegistry()
+		config.Registry.MustRegister(
+			prometheus.NewGoCollector(),
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		)
+	}
+
+	s.metrics = newRequestMetrics(config.Registry)
+
+	handler := promhttp.HandlerFor(config.Registry, promhttp.HandlerOpts{})
+	router.Handle(config.Path, s.guard(config.Auth, handler)).Methods(http.MethodGet)
+}
@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prometheus", func() {
+	usersEndpoint := func() *api.Endpoint {
+		return &api.Endpoint{
+			Method: http.MethodGet,
+			Path:   "/users/{id}",
+			Auth:   auth.None,
+			Handle: func(r api.Request) *api.Response {
+				return api.Ok(nil)
+			},
+		}
+	}
+
+	It("serves request metrics labelled by route template", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient:  testhelpers.NewFakeUAAClient(),
+			Port:       port,
+			Prometheus: &api.PrometheusConfig{},
+			Endpoints:  []*api.Endpoint{usersEndpoint()},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, id := range []string{"1", "2"} {
+			_, err := http.Get("http://localhost:" + port + "/users/" + id)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		resp, err := http.Get("http://localhost:" + port + "/metrics")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(ContainSubstring("text/plain"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring(`http_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`))
+		Expect(string(body)).To(ContainSubstring(`http_request_duration_seconds_count{method="GET",route="/users/{id}",status="2xx"} 2`))
+		Expect(string(body)).To(ContainSubstring(`http_requests_in_flight{method="GET",route="/users/{id}"} 0`))
+		Expect(string(body)).To(ContainSubstring("go_goroutines"))
+	})
+
+	It("serves metrics on a configured path with its own auth", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		uaaClient := testhelpers.NewFakeUAAClient()
+		server := api.New(api.Config{
+			UAAClient: uaaClient,
+			Port:      port,
+			Prometheus: &api.PrometheusConfig{
+				Path: "/internal/metrics",
+				Auth: auth.AnyScope("metrics.read"),
+			},
+			Endpoints: []*api.Endpoint{usersEndpoint()},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("not being logged in")
+		resp, err := http.Get("http://localhost:" + port + "/internal/metrics")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+		By("missing the scope")
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"openid"}})
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/internal/metrics", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		By("having the scope")
+		uaaClient.SetUser(&uaaclient.User{ID: "some-id", Scopes: []string{"metrics.read"}})
+		resp, err = testhelpers.AuthorizedRequest(http.MethodGet, "http://localhost:"+port+"/internal/metrics", "some-token", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("does not serve metrics unless configured", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{usersEndpoint()},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := http.Get("http://localhost:" + port + "/metrics")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,168 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/logger"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rcrowley/go-metrics"
+)
+
+var _ = Describe("Recovery", func() {
+	panics := func() int64 {
+		return metrics.GetOrRegisterCounter("api.panics", metrics.DefaultRegistry).Count()
+	}
+
+	It("turns panics and nil responses from endpoints into server errors", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		logs := testhelpers.NewFakeBuffer()
+		logRequestCalled := make(chan api.Response, 10)
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Logger:    logger.New("api", logs, logger.DEBUG),
+			LogRequest: func(req api.Request, resp api.Response, endpoint *api.Endpoint, startTime time.Time, totalTime time.Duration) {
+				logRequestCalled <- resp
+			},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/panic",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						panic("something went wrong")
+					},
+				},
+				{
+					Method: http.MethodGet,
+					Path:   "/nil",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return nil
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("recovering from a panic")
+		panicsBefore := panics()
+
+		resp, err := http.Get("http://localhost:" + port + "/panic")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "Internal Server Error"}]}`))
+
+		var loggedResp api.Response
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(loggedResp.Err).To(MatchError("panic: something went wrong"))
+
+		Expect(strings.Join(logs.GetContent(), "")).To(ContainSubstring("something went wrong"))
+		Expect(strings.Join(logs.GetContent(), "")).To(ContainSubstring("runtime/debug.Stack"))
+		Expect(panics()).To(Equal(panicsBefore + 1))
+
+		By("treating a nil response as a panic")
+		resp, err = http.Get("http://localhost:" + port + "/nil")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp.Err).To(MatchError("panic: endpoint returned a nil response"))
+	})
+
+	It("recovers from panics while writing the body", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		logs := testhelpers.NewFakeBuffer()
+		logRequestCalled := make(chan api.Response, 10)
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Logger:    logger.New("api", logs, logger.DEBUG),
+			LogRequest: func(req api.Request, resp api.Response, endpoint *api.Endpoint, startTime time.Time, totalTime time.Duration) {
+				logRequestCalled <- resp
+			},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/stream-panic",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.Ok(api.Stream(func(w http.ResponseWriter) error {
+							w.Header().Set("Content-Type", "text/csv")
+							panic("stream went wrong")
+						}))
+					},
+				},
+				{
+					Method: http.MethodGet,
+					Path:   "/stream-panic-late",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.Ok(api.Stream(func(w http.ResponseWriter) error {
+							w.Write([]byte("partial"))
+							w.(http.Flusher).Flush()
+							panic("stream went wrong")
+						}))
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("sending a server error if nothing was sent yet")
+		panicsBefore := panics()
+
+		resp, err := http.Get("http://localhost:" + port + "/stream-panic")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"errors": [{"description": "Internal Server Error"}]}`))
+
+		var loggedResp api.Response
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(loggedResp.Err).To(MatchError("panic: stream went wrong"))
+
+		Expect(strings.Join(logs.GetContent(), "")).To(ContainSubstring("stream went wrong"))
+		Expect(panics()).To(Equal(panicsBefore + 1))
+
+		By("cutting the connection if the body had started")
+		resp, err = http.Get("http://localhost:" + port + "/stream-panic-late")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		_, err = ioutil.ReadAll(resp.Body)
+		Expect(err).To(HaveOccurred())
+
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp.StatusCode).To(Equal(http.StatusOK))
+		Expect(loggedResp.BytesWritten).To(Equal(int64(len("partial"))))
+		Expect(loggedResp.Err).To(MatchError("panic: stream went wrong"))
+	})
+})
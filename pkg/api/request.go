@@ -1,14 +1,19 @@
 package api
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"reflect"
-
-	"encoding/json"
+	"strings"
+	"sync"
 
 	"io/ioutil"
 
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/validation"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/logger"
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
 	"github.com/gorilla/mux"
 )
@@ -17,15 +22,29 @@ type Request interface {
 	GetParam(name string) string
 	CurrentUser() *uaaclient.User
 	Decode(value interface{}) error
+	DecodeAndValidate(value interface{}) *Response
 	RawBody() []byte
 	Path() string
+	Context() context.Context
+	ID() string
+	Logger() *logger.Logger
 }
 
+var errNoBearerToken = errors.New("no bearer token in request")
+
 type realRequest struct {
-	httpRequest *http.Request
-	bodyRead    bool
-	body        []byte
-	currentUser *uaaclient.User
+	httpRequest  *http.Request
+	id           string
+	logger       *logger.Logger
+	maxBodyBytes int64
+	decoding     DecodeOptions
+	bodyRead     bool
+	body         []byte
+	bodyErr      error
+	uaaClient    UAAClient
+	userOnce     sync.Once
+	currentUser  *uaaclient.User
+	userErr      error
 }
 
 func (r *realRequest) GetParam(n string) string {
@@ -38,46 +57,122 @@ func (r *realRequest) GetParam(n string) string {
 }
 
 func (r *realRequest) CurrentUser() *uaaclient.User {
-	return r.currentUser
+	user, _ := r.checkToken()
+	return user
+}
+
+// checkToken asks UAA about the bearer token the first time the user is
+// needed, so requests that never look at the user never reach UAA.
+func (r *realRequest) checkToken() (*uaaclient.User, error) {
+	r.userOnce.Do(func() {
+		token := r.httpRequest.Header.Get("Authorization")
+		token = strings.TrimPrefix(token, "bearer ")
+		token = strings.TrimPrefix(token, "Bearer ")
+
+		if token == "" {
+			r.userErr = errNoBearerToken
+			return
+		}
+
+		r.currentUser, r.userErr = r.uaaClient.CheckToken(token, r.httpRequest.Context())
+	})
+
+	return r.currentUser, r.userErr
 }
 
 func (r *realRequest) Decode(target interface{}) error {
-	err := json.Unmarshal(r.RawBody(), target)
+	body, err := r.readBody()
 	if err != nil {
 		return err
 	}
 
-	r.httpRequest.Body.Close()
+	return decodeJSON(body, target, r.decoding)
+}
 
-	return nil
+// DecodeAndValidate decodes the body into value and checks it against its
+// `validate` struct tags. It returns nil if the body is valid, a 400 if it
+// could not be decoded and a 422 listing every broken rule otherwise.
+func (r *realRequest) DecodeAndValidate(value interface{}) *Response {
+	return decodeAndValidate(r, value)
 }
 
+// RawBody returns the request body, or an empty slice if it could not be
+// read. Decode reports the read error instead.
 func (r *realRequest) RawBody() []byte {
+	body, err := r.readBody()
+	if err != nil {
+		return []byte{}
+	}
+
+	return body
+}
+
+func (r *realRequest) readBody() ([]byte, error) {
 	if r.bodyRead {
-		return r.body
-	} else {
-		r.bodyRead = true
+		return r.body, r.bodyErr
+	}
+	r.bodyRead = true
+	defer r.httpRequest.Body.Close()
 
-		b, err := ioutil.ReadAll(r.httpRequest.Body)
-		if err != nil {
-			return []byte{}
-		}
+	var body io.Reader = r.httpRequest.Body
+	if r.maxBodyBytes > 0 {
+		// one byte over the limit is enough to know it was exceeded
+		body = io.LimitReader(body, r.maxBodyBytes+1)
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		r.bodyErr = fmt.Errorf("reading request body: %s", err)
+		return nil, r.bodyErr
+	}
 
-		r.body = b
-		r.httpRequest.Body.Close()
-		return r.body
+	if r.maxBodyBytes > 0 && int64(len(b)) > r.maxBodyBytes {
+		r.bodyErr = errBodyTooLarge(r.maxBodyBytes)
+		return nil, r.bodyErr
 	}
+
+	r.body = b
+	return r.body, nil
 }
 
 func (r *realRequest) Path() string {
 	return r.httpRequest.URL.Path
 }
 
+func (r *realRequest) Context() context.Context {
+	return r.httpRequest.Context()
+}
+
+func (r *realRequest) ID() string {
+	return r.id
+}
+
+func (r *realRequest) Logger() *logger.Logger {
+	return r.logger
+}
+
+func decodeAndValidate(r Request, value interface{}) *Response {
+	err := r.Decode(value)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	errs := validation.Validate(value)
+	if len(errs) > 0 {
+		return ValidationError(errs)
+	}
+
+	return nil
+}
+
 type FakeRequest struct {
 	User          uaaclient.User
 	Params        map[string]string
 	Body          interface{}
 	ErrorOnDecode bool
+	Ctx           context.Context
+	RequestID     string
+	Log           *logger.Logger
 }
 
 func (f *FakeRequest) GetParam(n string) string {
@@ -99,6 +194,10 @@ func (f *FakeRequest) Decode(target interface{}) error {
 	return nil
 }
 
+func (f *FakeRequest) DecodeAndValidate(target interface{}) *Response {
+	return decodeAndValidate(f, target)
+}
+
 func (f *FakeRequest) RawBody() []byte {
 	return []byte("{}")
 }
@@ -106,3 +205,23 @@ func (f *FakeRequest) RawBody() []byte {
 func (f *FakeRequest) Path() string {
 	return "/"
 }
+
+func (f *FakeRequest) Context() context.Context {
+	if f.Ctx == nil {
+		return context.Background()
+	}
+
+	return f.Ctx
+}
+
+func (f *FakeRequest) ID() string {
+	return f.RequestID
+}
+
+func (f *FakeRequest) Logger() *logger.Logger {
+	if f.Log == nil {
+		return logger.New("fake", ioutil.Discard, logger.FATAL)
+	}
+
+	return f.Log
+}
@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/requestid"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request IDs", func() {
+	It("passes the request id to the endpoint, the response and the request log", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		handledIDs := make(chan string, 10)
+		loggedIDs := make(chan string, 10)
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			LogRequest: func(req api.Request, resp api.Response, endpoint *api.Endpoint, startTime time.Time, totalTime time.Duration) {
+				loggedIDs <- req.ID()
+			},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/some-endpoint",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						handledIDs <- r.ID()
+						handledIDs <- requestid.FromContext(r.Context())
+
+						return api.NoContent()
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("using the incoming request id")
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+"/some-endpoint", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("X-Vcap-Request-Id", "some-request-id")
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Header.Get("X-Request-Id")).To(Equal("some-request-id"))
+
+		Eventually(handledIDs).Should(Receive(Equal("some-request-id")))
+		Eventually(handledIDs).Should(Receive(Equal("some-request-id")))
+		Eventually(loggedIDs).Should(Receive(Equal("some-request-id")))
+
+		By("generating a request id when there is none")
+		resp, err = http.Get("http://localhost:" + port + "/some-endpoint")
+		Expect(err).ToNot(HaveOccurred())
+
+		id := resp.Header.Get("X-Request-Id")
+		Expect(id).ToNot(BeEmpty())
+
+		Eventually(handledIDs).Should(Receive(Equal(id)))
+		Eventually(handledIDs).Should(Receive(Equal(id)))
+		Eventually(loggedIDs).Should(Receive(Equal(id)))
+	})
+})
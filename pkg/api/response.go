@@ -10,6 +10,12 @@ type Response struct {
 	StatusCode int
 	Body       interface{}
 	Header     http.Header
+	// Err is the reason behind an error response. It is passed to the
+	// request logger but never sent to the client.
+	Err error
+	// BytesWritten is set once the response has been sent, for the request
+	// logger.
+	BytesWritten int64
 }
 
 func Ok(body interface{}) *Response {
@@ -61,6 +67,20 @@ func UnprocessableEntity(err error) *Response {
 	}
 }
 
+func RequestEntityTooLarge(err error) *Response {
+	return &Response{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Body:       wrapError(err),
+	}
+}
+
+func UnsupportedMediaType(err error) *Response {
+	return &Response{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Body:       wrapError(err),
+	}
+}
+
 func ServerError(err error) *Response {
 	return &Response{
 		StatusCode: http.StatusInternalServerError,
@@ -90,9 +110,13 @@ func Forbidden(err error) *Response {
 }
 
 func wrapError(err error) errors.ErrorListResponse {
+	errorResponse := errors.ErrorResponse{Description: err.Error()}
+	if fieldErr, ok := err.(*fieldError); ok {
+		errorResponse.Field = fieldErr.field
+		errorResponse.Code = fieldErr.code
+	}
+
 	return errors.ErrorListResponse{
-		Errors: []errors.ErrorResponse{
-			{Description: err.Error()},
-		},
+		Errors: []errors.ErrorResponse{errorResponse},
 	}
 }
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// Stream is a response body that writes itself. Headers set on w before the
+// first write are sent, and the Response's status code is used unless the
+// func calls WriteHeader. An error is logged with the request, but the
+// client only sees the body end early.
+type Stream func(w http.ResponseWriter) error
+
+// NDJSON is a response body sent as newline delimited JSON, one line per item
+// returned by Next, flushing as it goes. Next returns ok false once there are
+// no more items.
+type NDJSON struct {
+	Next func() (item interface{}, ok bool, err error)
+}
+
+// NDJSONFromChannel streams every value received from ch, which must be a
+// channel that can be received from, until it is closed.
+func NDJSONFromChannel(ch interface{}) NDJSON {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("NDJSONFromChannel: %T is not a receivable channel", ch))
+	}
+
+	return NDJSON{
+		Next: func() (interface{}, bool, error) {
+			item, ok := v.Recv()
+			if !ok {
+				return nil, false, nil
+			}
+
+			return item.Interface(), true, nil
+		},
+	}
+}
+
+func (n NDJSON) write(w http.ResponseWriter) error {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		item, ok, err := n.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		// Encode ends each item with the newline NDJSON needs
+		err = encoder.Encode(item)
+		if err != nil {
+			return err
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func writeReader(w http.ResponseWriter, r io.Reader) error {
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// responseRecorder keeps the status and the number of body bytes sent, for
+// the metrics and the request logger.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(w.status)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func (w *responseRecorder) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	if !w.wroteHeader {
+		w.WriteHeader(w.status)
+	}
+	flusher.Flush()
+}
@@ -0,0 +1,177 @@
+package api_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/auth"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streaming", func() {
+	type Preference struct {
+		UserID string `json:"user_id"`
+	}
+
+	It("copies io.Reader bodies", func() {
+		w := httptest.NewRecorder()
+		err := api.WriteReader(w, strings.NewReader("a,b\nc,d\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/octet-stream"))
+		Expect(w.Body.String()).To(Equal("a,b\nc,d\n"))
+
+		w = httptest.NewRecorder()
+		w.Header().Set("Content-Type", "text/csv")
+		err = api.WriteReader(w, strings.NewReader("a,b\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Header().Get("Content-Type")).To(Equal("text/csv"))
+	})
+
+	It("sends NDJSON from a channel, flushing each item", func() {
+		preferences := make(chan Preference)
+		go func() {
+			defer close(preferences)
+			for _, id := range []string{"a", "b", "c"} {
+				preferences <- Preference{UserID: id}
+			}
+		}()
+
+		w := httptest.NewRecorder()
+		err := api.NDJSONFromChannel(preferences).Write(w)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+		Expect(w.Body.String()).To(Equal("{\"user_id\":\"a\"}\n{\"user_id\":\"b\"}\n{\"user_id\":\"c\"}\n"))
+		Expect(w.Flushed).To(BeTrue())
+	})
+
+	It("stops NDJSON at the first error", func() {
+		items := 0
+		ndjson := api.NDJSON{
+			Next: func() (interface{}, bool, error) {
+				items++
+				if items > 1 {
+					return nil, false, errors.New("export failed")
+				}
+
+				return items, true, nil
+			},
+		}
+
+		w := httptest.NewRecorder()
+		Expect(ndjson.Write(w)).To(MatchError("export failed"))
+		Expect(w.Body.String()).To(Equal("1\n"))
+	})
+
+	It("refuses values that are not channels", func() {
+		Expect(func() { api.NDJSONFromChannel([]string{"a"}) }).To(Panic())
+	})
+
+	It("streams response bodies, logging what was sent", func() {
+		port, err := testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+
+		logRequestCalled := make(chan api.Response, 10)
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			LogRequest: func(req api.Request, resp api.Response, endpoint *api.Endpoint, startTime time.Time, totalTime time.Duration) {
+				logRequestCalled <- resp
+			},
+			Endpoints: []*api.Endpoint{
+				{
+					Method: http.MethodGet,
+					Path:   "/reader",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return &api.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": []string{"text/csv"}},
+							Body:       strings.NewReader("a,b\nc,d\n"),
+						}
+					},
+				},
+				{
+					Method: http.MethodGet,
+					Path:   "/stream",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return &api.Response{
+							StatusCode: http.StatusOK,
+							Body: api.Stream(func(w http.ResponseWriter) error {
+								w.Header().Set("Content-Type", "text/plain")
+								w.WriteHeader(http.StatusPartialContent)
+								_, err := io.WriteString(w, "partial")
+								return err
+							}),
+						}
+					},
+				},
+				{
+					Method: http.MethodGet,
+					Path:   "/failing",
+					Auth:   auth.None,
+					Handle: func(r api.Request) *api.Response {
+						return api.Ok(api.Stream(func(w http.ResponseWriter) error {
+							io.WriteString(w, "some")
+							return errors.New("export failed")
+						}))
+					},
+				},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		get := func(path string) (*http.Response, string) {
+			resp, err := http.Get("http://localhost:" + port + path)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return resp, string(body)
+		}
+
+		By("copying io.Reader bodies")
+		resp, body := get("/reader")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/csv"))
+		Expect(body).To(Equal("a,b\nc,d\n"))
+		Eventually(logRequestCalled).Should(Receive(haveSent(http.StatusOK, 8)))
+
+		By("letting a Stream write the response")
+		resp, body = get("/stream")
+		Expect(resp.StatusCode).To(Equal(http.StatusPartialContent))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/plain"))
+		Expect(body).To(Equal("partial"))
+		Eventually(logRequestCalled).Should(Receive(haveSent(http.StatusPartialContent, 7)))
+
+		By("logging the error of a failed Stream")
+		resp, body = get("/failing")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("some"))
+
+		var loggedResp api.Response
+		Eventually(logRequestCalled).Should(Receive(&loggedResp))
+		Expect(loggedResp).To(haveSent(http.StatusOK, 4))
+		Expect(loggedResp.Err).To(MatchError("export failed"))
+	})
+})
+
+func haveSent(status int, bytes int64) OmegaMatcher {
+	return And(
+		WithTransform(func(r api.Response) int { return r.StatusCode }, Equal(status)),
+		WithTransform(func(r api.Response) int64 { return r.BytesWritten }, Equal(bytes)),
+	)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TLSConfig enables TLS on the server. The certificate either comes from
+// CertFile and KeyFile, which are reloaded when they change on disk, or from
+// Config. Setting ClientCAFile requires clients to present a certificate
+// signed by one of the CAs in that file.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	Config       *tls.Config
+}
+
+func (c *TLSConfig) build() (*tls.Config, error) {
+	var tlsConfig *tls.Config
+	if c.Config != nil {
+		tlsConfig = c.Config.Clone()
+	} else {
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		reloader, err := newCertReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = nil
+		tlsConfig.GetCertificate = reloader.getCertificate
+	}
+
+	if len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil {
+		return nil, errors.New("tls requires either a cert and key file or a tls.Config with certificates")
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// certCheckInterval is the least time between checks of the cert and key
+// files for changes, so handshakes do not wait on the file system.
+var certCheckInterval = 5 * time.Second
+
+// certReloader serves the certificate from an atomic.Value. One handshake per
+// certCheckInterval looks at the files, and every other handshake gets the
+// current certificate without waiting.
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	cert      atomic.Value
+	nextCheck int64
+
+	mu          sync.Mutex
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a cert file and a key file must be set")
+	}
+
+	r := &certReloader{
+		certFile:  certFile,
+		keyFile:   keyFile,
+		nextCheck: time.Now().Add(certCheckInterval).UnixNano(),
+	}
+
+	err := r.reloadIfChanged()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	now := time.Now()
+	next := atomic.LoadInt64(&r.nextCheck)
+	if now.UnixNano() >= next && atomic.CompareAndSwapInt64(&r.nextCheck, next, now.Add(certCheckInterval).UnixNano()) {
+		r.mu.Lock()
+		// a rotation that is only half written keeps serving the old
+		// certificate
+		r.reloadIfChanged()
+		r.mu.Unlock()
+	}
+
+	return r.cert.Load().(*tls.Certificate), nil
+}
+
+func (r *certReloader) reloadIfChanged() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if r.cert.Load() != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert.Store(&cert)
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package api_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var (
+		certDir string
+		ca      *testhelpers.CertificateAuthority
+		port    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		certDir, err = ioutil.TempDir("", "api-tls")
+		Expect(err).ToNot(HaveOccurred())
+
+		ca, err = testhelpers.NewCertificateAuthority(certDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		port, err = testhelpers.GetOpenPort()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(certDir)
+	})
+
+	get := func(tlsConfig *tls.Config) (*http.Response, error) {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}
+
+		return client.Get("https://localhost:" + port + "/v1/info")
+	}
+
+	It("serves https with a cert and key file", func() {
+		certFile, keyFile, err := ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+			TLS: &api.TLSConfig{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := get(ca.Client())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("serves https with a tls.Config", func() {
+		certFile, keyFile, err := ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+			TLS: &api.TLSConfig{
+				Config: &tls.Config{Certificates: []tls.Certificate{cert}},
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := get(ca.Client())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("requires a client certificate signed by the client CA", func() {
+		certFile, keyFile, err := ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		clientCertFile, clientKeyFile, err := ca.Issue(certDir, "client")
+		Expect(err).ToNot(HaveOccurred())
+
+		clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+			TLS: &api.TLSConfig{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: ca.CertFile,
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("not presenting a client certificate")
+		_, err = get(ca.Client())
+		Expect(err).To(HaveOccurred())
+
+		By("presenting a client certificate")
+		resp, err := get(ca.Client(clientCert))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("picks up a rotated certificate", func() {
+		defer api.SetCertCheckInterval(100 * time.Millisecond)()
+
+		certFile, keyFile, err := ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+			TLS: &api.TLSConfig{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := get(ca.Client())
+		Expect(err).ToNot(HaveOccurred())
+		firstSerial := resp.TLS.PeerCertificates[0].SerialNumber
+
+		_, _, err = ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(certFile, later, later)).To(Succeed())
+		Expect(os.Chtimes(keyFile, later, later)).To(Succeed())
+
+		Eventually(func() interface{} {
+			resp, err := get(ca.Client())
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+
+			return resp.TLS.PeerCertificates[0].SerialNumber
+		}).ShouldNot(Equal(firstSerial))
+	})
+
+	It("checks the certificate files at most once per interval", func() {
+		defer api.SetCertCheckInterval(time.Hour)()
+
+		certFile, keyFile, err := ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			Endpoints: []*api.Endpoint{api.InfoEndpoint(&api.InfoResponse{})},
+			TLS: &api.TLSConfig{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+			},
+		})
+		stop := server.Start()
+		defer stop()
+
+		err = testhelpers.PollForUp(port)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := get(ca.Client())
+		Expect(err).ToNot(HaveOccurred())
+		firstSerial := resp.TLS.PeerCertificates[0].SerialNumber
+
+		_, _, err = ca.Issue(certDir, "server")
+		Expect(err).ToNot(HaveOccurred())
+
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(certFile, later, later)).To(Succeed())
+		Expect(os.Chtimes(keyFile, later, later)).To(Succeed())
+
+		resp, err = get(ca.Client())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.TLS.PeerCertificates[0].SerialNumber).To(Equal(firstSerial))
+	})
+
+	It("returns an error from Run if the certificate cannot be loaded", func() {
+		server := api.New(api.Config{
+			UAAClient: testhelpers.NewFakeUAAClient(),
+			Port:      port,
+			TLS: &api.TLSConfig{
+				CertFile: "/non-existent.crt",
+				KeyFile:  "/non-existent.key",
+			},
+		})
+
+		err := server.Run(context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,265 @@
+// Package validation checks decoded request bodies against rules declared in
+// `validate` struct tags, e.g.
+//
+//	type User struct {
+//		Name  string  `json:"name" validate:"required,min=3,max=50"`
+//		Role  string  `json:"role" validate:"oneof=admin user"`
+//		Email string  `json:"email" validate:"required,email"`
+//		ID    string  `json:"id" validate:"uuid"`
+//		Home  Address `json:"home"`
+//	}
+//
+// Nested structs, pointers to structs and slices of structs are checked too.
+// Rules other than required only apply to values that are set, so an empty
+// optional field is always valid.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/errors"
+)
+
+// The codes set on each ErrorResponse, so clients can tell failures apart
+// without parsing descriptions.
+const (
+	CodeRequired = "required"
+	CodeTooShort = "too_short"
+	CodeTooLong  = "too_long"
+	CodeTooSmall = "too_small"
+	CodeTooLarge = "too_large"
+	CodeOneOf    = "not_allowed"
+	CodeEmail    = "invalid_email"
+	CodeUUID     = "invalid_uuid"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate returns one ErrorResponse per broken rule in v, which must be a
+// struct or a pointer to one. Field holds the JSON path to the value, such as
+// "home.city" or "items[2].name".
+func Validate(v interface{}) []errors.ErrorResponse {
+	var errs []errors.ErrorResponse
+	validateValue(reflect.ValueOf(v), "", &errs)
+
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *[]errors.ErrorResponse) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateStruct(v reflect.Value, path string, errs *[]errors.ErrorResponse) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			// embedded fields are decoded as if they were declared here
+			validateValue(v.Field(i), path, errs)
+			continue
+		}
+
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		value := v.Field(i)
+		if tag != "" {
+			ok = validateField(value, name, strings.Split(tag, ","), errs)
+			if !ok {
+				continue
+			}
+		}
+
+		validateValue(value, name, errs)
+	}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+
+	if name == "" {
+		return field.Name, true
+	}
+
+	return name, true
+}
+
+// validateField applies rules to value and reports whether it passed all of
+// them.
+func validateField(value reflect.Value, name string, rules []string, errs *[]errors.ErrorResponse) bool {
+	fail := func(code, format string, args ...interface{}) {
+		*errs = append(*errs, errors.ErrorResponse{
+			Description: name + " " + fmt.Sprintf(format, args...),
+			Field:       name,
+			Code:        code,
+		})
+	}
+
+	if isZero(value) {
+		for _, rule := range rules {
+			if rule == "required" {
+				fail(CodeRequired, "is required")
+				return false
+			}
+		}
+
+		return true
+	}
+
+	value = reflect.Indirect(value)
+	passed := true
+
+	for _, rule := range rules {
+		rule, arg := splitRule(rule)
+
+		switch rule {
+		case "", "required":
+
+		case "min":
+			n := mustParse(name, rule, arg)
+			if size, unit, ok := measure(value); ok && size < n {
+				passed = false
+				if unit != "" {
+					fail(CodeTooShort, "must have at least %s %s", arg, unit)
+				} else {
+					fail(CodeTooSmall, "must be at least %s", arg)
+				}
+			}
+
+		case "max":
+			n := mustParse(name, rule, arg)
+			if size, unit, ok := measure(value); ok && size > n {
+				passed = false
+				if unit != "" {
+					fail(CodeTooLong, "must have at most %s %s", arg, unit)
+				} else {
+					fail(CodeTooLarge, "must be at most %s", arg)
+				}
+			}
+
+		case "oneof":
+			allowed := strings.Fields(arg)
+			s := fmt.Sprint(value.Interface())
+			if !contains(allowed, s) {
+				passed = false
+				fail(CodeOneOf, "must be one of: %s", strings.Join(allowed, ", "))
+			}
+
+		case "email":
+			addr, err := mail.ParseAddress(value.String())
+			if err != nil || addr.Address != value.String() {
+				passed = false
+				fail(CodeEmail, "must be an email address")
+			}
+
+		case "uuid":
+			if !uuidPattern.MatchString(value.String()) {
+				passed = false
+				fail(CodeUUID, "must be a UUID")
+			}
+
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", rule, name))
+		}
+	}
+
+	return passed
+}
+
+func splitRule(rule string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// mustParse panics on a malformed tag, which is a programming error rather
+// than bad input.
+func mustParse(name, rule, arg string) float64 {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: %s=%q on %s is not a number", rule, arg, name))
+	}
+
+	return n
+}
+
+// measure returns the length of strings, slices and maps along with its unit,
+// or the value of numbers with no unit.
+func measure(v reflect.Value) (size float64, unit string, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+
+	return 0, "", false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
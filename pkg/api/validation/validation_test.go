@@ -0,0 +1,108 @@
+package validation_test
+
+import (
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/errors"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/api/validation"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type Address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"oneof=DE GB US"`
+}
+
+type Item struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type Base struct {
+	ID string `json:"id" validate:"uuid"`
+}
+
+type User struct {
+	Base
+	Name     string   `json:"name" validate:"required,min=3,max=10"`
+	Email    string   `json:"email" validate:"email"`
+	Age      int      `json:"age" validate:"min=18,max=130"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Home     Address  `json:"home"`
+	Work     *Address `json:"work,omitempty"`
+	Items    []Item   `json:"items"`
+	Nickname string
+	Ignored  string `json:"-" validate:"required"`
+}
+
+func validUser() User {
+	return User{
+		Base:  Base{ID: "1b4e28ba-2fa1-11d2-883f-0016d3cca427"},
+		Name:  "Alice",
+		Email: "alice@example.com",
+		Age:   30,
+		Home:  Address{City: "Berlin", Country: "DE"},
+		Items: []Item{{Name: "a"}},
+	}
+}
+
+var _ = Describe("Validate", func() {
+	It("accepts valid values", func() {
+		user := validUser()
+
+		Expect(validation.Validate(user)).To(BeEmpty())
+		Expect(validation.Validate(&user)).To(BeEmpty())
+	})
+
+	It("only applies rules other than required to values that are set", func() {
+		user := validUser()
+		user.ID = ""
+		user.Email = ""
+		user.Age = 0
+		user.Home.Country = ""
+
+		Expect(validation.Validate(user)).To(BeEmpty())
+	})
+
+	It("reports every broken rule with its field and code", func() {
+		user := validUser()
+		user.ID = "not-a-uuid"
+		user.Name = ""
+		user.Email = "Alice <alice@example.com>"
+		user.Age = 12
+		user.Tags = []string{"a", "b", "c"}
+		user.Home = Address{Country: "FR"}
+		user.Work = &Address{City: "London", Country: "GB"}
+		user.Items = []Item{{Name: "a"}, {Name: "toolong"}}
+
+		Expect(validation.Validate(user)).To(ConsistOf(
+			errors.ErrorResponse{Field: "id", Code: validation.CodeUUID, Description: "id must be a UUID"},
+			errors.ErrorResponse{Field: "name", Code: validation.CodeRequired, Description: "name is required"},
+			errors.ErrorResponse{Field: "email", Code: validation.CodeEmail, Description: "email must be an email address"},
+			errors.ErrorResponse{Field: "age", Code: validation.CodeTooSmall, Description: "age must be at least 18"},
+			errors.ErrorResponse{Field: "tags", Code: validation.CodeTooLong, Description: "tags must have at most 2 items"},
+			errors.ErrorResponse{Field: "home.city", Code: validation.CodeRequired, Description: "home.city is required"},
+			errors.ErrorResponse{Field: "home.country", Code: validation.CodeOneOf, Description: "home.country must be one of: DE, GB, US"},
+			errors.ErrorResponse{Field: "items[1].name", Code: validation.CodeTooLong, Description: "items[1].name must have at most 5 characters"},
+		))
+	})
+
+	It("counts string length in characters", func() {
+		user := validUser()
+		user.Name = "Zoë"
+
+		Expect(validation.Validate(user)).To(BeEmpty())
+
+		user.Name = "Zo"
+		Expect(validation.Validate(user)).To(ConsistOf(
+			errors.ErrorResponse{Field: "name", Code: validation.CodeTooShort, Description: "name must have at least 3 characters"},
+		))
+	})
+
+	It("panics on unknown rules", func() {
+		type Broken struct {
+			Name string `validate:"shiny"`
+		}
+
+		Expect(func() { validation.Validate(Broken{Name: "x"}) }).To(Panic())
+	})
+})
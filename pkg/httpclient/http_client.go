@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/requestid"
 )
 
 type HTTPClient struct {
@@ -74,8 +76,24 @@ func (c *HTTPClient) DeleteRequest(endpoint string, body io.Reader) (*http.Reque
 	return req, nil
 }
 
+// Do sends req with ctx. If ctx carries a request id it is forwarded in the
+// X-Vcap-Request-Id header, so the call can be matched up with the request
+// that caused it.
 func (c *HTTPClient) Do(req *http.Request, ctx context.Context) (*http.Response, error) {
-	resp, err := c.client.Do(req.WithContext(ctx))
+	req = req.WithContext(ctx)
+
+	id := requestid.FromContext(ctx)
+	if id != "" && req.Header.Get(requestid.VcapHeader) == "" {
+		header := req.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		header.Set(requestid.VcapHeader, id)
+		req.Header = header
+	}
+
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
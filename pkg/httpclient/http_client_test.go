@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/httpclient"
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/requestid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -104,6 +105,27 @@ var _ = Describe("HTTPClient", func() {
 		Expect(output).To(MatchJSON(`{"some": "json"}`))
 	})
 
+	It("forwards the request id from the context", func() {
+		requests := make(chan *http.Request, 1)
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+		}))
+		defer mockServer.Close()
+
+		c, err := httpclient.New(mockServer.URL, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := c.GetRequest("/endpoint")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = c.Do(req, requestid.NewContext(context.Background(), "some-request-id"))
+		Expect(err).ToNot(HaveOccurred())
+
+		request := <-requests
+		Expect(request.Header.Get("X-Vcap-Request-Id")).To(Equal("some-request-id"))
+		Expect(req.Header.Get("X-Vcap-Request-Id")).To(BeEmpty())
+	})
+
 	It("returns an error if the host is empty", func() {
 		_, err := httpclient.New("", true)
 		Expect(err).To(MatchError("host cannot be empty"))
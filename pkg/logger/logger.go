@@ -5,6 +5,8 @@ import (
 	"log"
 )
 
+// Err and Out are plain loggers set up by Init. New code should prefer the
+// structured Logger returned by New.
 var (
 	Err *log.Logger
 	Out *log.Logger
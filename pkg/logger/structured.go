@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type Level int
+
+// the numbering matches lager's log_level
+const (
+	DEBUG Level = iota
+	INFO
+	ERROR
+	FATAL
+)
+
+func (l Level) String() string {
+	switch l {
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case ERROR:
+		return "error"
+	case FATAL:
+		return "fatal"
+	}
+
+	return "unknown"
+}
+
+type Fields map[string]interface{}
+
+// Logger writes one JSON object per line in the format lager uses, so the
+// lines read the same as other CF components once they reach loggregator.
+type Logger struct {
+	out       *syncWriter
+	component string
+	minLevel  Level
+	fields    Fields
+	now       func() time.Time
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type logLine struct {
+	Timestamp string `json:"timestamp"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+	LogLevel  Level  `json:"log_level"`
+	Data      Fields `json:"data"`
+}
+
+// New returns a Logger that writes lines at minLevel and above to w. The
+// component is used as the source and prefixes every message.
+func New(component string, w io.Writer, minLevel Level) *Logger {
+	return &Logger{
+		out:       &syncWriter{w: w},
+		component: component,
+		minLevel:  minLevel,
+		fields:    Fields{},
+		now:       time.Now,
+	}
+}
+
+// With returns a child logger that adds fields to every line it writes.
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	child := *l
+	child.fields = merged
+
+	return &child
+}
+
+// WithRequestID returns a child logger for the lines written while serving
+// one request.
+func (l *Logger) WithRequestID(id string) *Logger {
+	return l.With(Fields{"request_id": id})
+}
+
+func (l *Logger) Debug(message string, fields ...Fields) {
+	l.log(DEBUG, message, nil, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Fields) {
+	l.log(INFO, message, nil, fields)
+}
+
+func (l *Logger) Error(message string, err error, fields ...Fields) {
+	l.log(ERROR, message, err, fields)
+}
+
+func (l *Logger) log(level Level, message string, err error, fields []Fields) {
+	if level < l.minLevel {
+		return
+	}
+
+	data := make(Fields, len(l.fields))
+	for k, v := range l.fields {
+		data[k] = v
+	}
+	for _, f := range fields {
+		for k, v := range f {
+			data[k] = v
+		}
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+
+	now := l.now()
+	line := logLine{
+		Timestamp: fmt.Sprintf("%d.%09d", now.Unix(), now.Nanosecond()),
+		Source:    l.component,
+		Message:   l.component + "." + message,
+		LogLevel:  level,
+		Data:      data,
+	}
+
+	b, marshalErr := json.Marshal(line)
+	if marshalErr != nil {
+		// a field that cannot be marshalled should not lose the whole line
+		line.Data = Fields{"log_error": marshalErr.Error()}
+		b, _ = json.Marshal(line)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(append(b, '\n'))
+}
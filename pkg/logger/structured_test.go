@@ -0,0 +1,93 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Structured Logger", func() {
+	var b bytes.Buffer
+
+	BeforeEach(func() {
+		b.Reset()
+	})
+
+	lines := func() []map[string]interface{} {
+		var result []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			if line == "" {
+				continue
+			}
+
+			var l map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &l)).To(Succeed())
+			result = append(result, l)
+		}
+
+		return result
+	}
+
+	It("writes lager formatted json lines", func() {
+		l := logger.New("notifications", &b, logger.DEBUG)
+
+		l.Info("request", logger.Fields{"status": 200})
+
+		Expect(lines()).To(HaveLen(1))
+		line := lines()[0]
+		Expect(line["source"]).To(Equal("notifications"))
+		Expect(line["message"]).To(Equal("notifications.request"))
+		Expect(line["log_level"]).To(BeEquivalentTo(1))
+		Expect(line["timestamp"]).To(MatchRegexp(`^\d+\.\d{9}$`))
+		Expect(line["data"]).To(Equal(map[string]interface{}{"status": float64(200)}))
+	})
+
+	It("includes the error in error lines", func() {
+		l := logger.New("notifications", &b, logger.DEBUG)
+
+		l.Error("failed", errors.New("some-error"))
+
+		line := lines()[0]
+		Expect(line["log_level"]).To(BeEquivalentTo(2))
+		Expect(line["data"]).To(HaveKeyWithValue("error", "some-error"))
+	})
+
+	It("skips lines below the minimum level", func() {
+		l := logger.New("notifications", &b, logger.INFO)
+
+		l.Debug("noisy")
+		l.Info("useful")
+
+		Expect(lines()).To(HaveLen(1))
+		Expect(lines()[0]["message"]).To(Equal("notifications.useful"))
+	})
+
+	It("adds the fields of child loggers", func() {
+		l := logger.New("notifications", &b, logger.DEBUG)
+		child := l.WithRequestID("some-id").With(logger.Fields{"user": "some-user"})
+
+		child.Info("request", logger.Fields{"status": 200})
+		l.Info("startup")
+
+		Expect(lines()[0]["data"]).To(Equal(map[string]interface{}{
+			"request_id": "some-id",
+			"user":       "some-user",
+			"status":     float64(200),
+		}))
+		Expect(lines()[1]["data"]).To(BeEmpty())
+	})
+
+	It("still logs when a field cannot be marshalled", func() {
+		l := logger.New("notifications", &b, logger.DEBUG)
+
+		l.Info("request", logger.Fields{"bad": make(chan int)})
+
+		Expect(lines()[0]["message"]).To(Equal("notifications.request"))
+		Expect(lines()[0]["data"]).To(HaveKey("log_error"))
+	})
+})
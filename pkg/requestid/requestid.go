@@ -0,0 +1,68 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const (
+	Header     = "X-Request-Id"
+	VcapHeader = "X-Vcap-Request-Id"
+
+	maxLength = 200
+)
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id carried by ctx, or an empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromRequest returns the id the gorouter or the caller gave the request, or
+// a new one if it has none or it is not safe to log and echo back.
+func FromRequest(r *http.Request) string {
+	for _, header := range []string{VcapHeader, Header} {
+		id := r.Header.Get(header)
+		if valid(id) {
+			return id
+		}
+	}
+
+	return New()
+}
+
+// New returns a random version 4 UUID.
+func New() string {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
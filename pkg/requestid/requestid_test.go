@@ -0,0 +1,62 @@
+package requestid_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/requestid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestID", func() {
+	It("stores the id in a context", func() {
+		ctx := requestid.NewContext(context.Background(), "some-id")
+
+		Expect(requestid.FromContext(ctx)).To(Equal("some-id"))
+		Expect(requestid.FromContext(context.Background())).To(BeEmpty())
+	})
+
+	It("generates uuids", func() {
+		id := requestid.New()
+
+		Expect(id).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+		Expect(requestid.New()).ToNot(Equal(id))
+	})
+
+	Describe("FromRequest", func() {
+		var r *http.Request
+
+		BeforeEach(func() {
+			var err error
+			r, err = http.NewRequest(http.MethodGet, "/", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("prefers the id from the gorouter", func() {
+			r.Header.Set(requestid.Header, "client-id")
+			r.Header.Set(requestid.VcapHeader, "router-id")
+
+			Expect(requestid.FromRequest(r)).To(Equal("router-id"))
+		})
+
+		It("uses the id from the caller", func() {
+			r.Header.Set(requestid.Header, "client-id")
+
+			Expect(requestid.FromRequest(r)).To(Equal("client-id"))
+		})
+
+		It("generates an id when there is none", func() {
+			Expect(requestid.FromRequest(r)).ToNot(BeEmpty())
+		})
+
+		It("generates an id when the given one is unsafe", func() {
+			r.Header.Set(requestid.Header, "bad id\n")
+			Expect(requestid.FromRequest(r)).ToNot(Equal("bad id\n"))
+
+			r.Header.Set(requestid.Header, strings.Repeat("a", 201))
+			Expect(requestid.FromRequest(r)).To(HaveLen(36))
+		})
+	})
+})
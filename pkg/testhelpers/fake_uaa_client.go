@@ -9,9 +9,11 @@ import (
 )
 
 type fakeUAAClient struct {
-	mu   sync.Mutex
-	user *uaaclient.User
-	err  error
+	mu        sync.Mutex
+	user      *uaaclient.User
+	err       error
+	callCount int
+	lastToken string
 }
 
 func NewFakeUAAClient() *fakeUAAClient {
@@ -22,9 +24,26 @@ func (f *fakeUAAClient) CheckToken(token string, ctx context.Context) (*uaaclien
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	f.callCount++
+	f.lastToken = token
+
 	return f.user, f.err
 }
 
+func (f *fakeUAAClient) CallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.callCount
+}
+
+func (f *fakeUAAClient) LastToken() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.lastToken
+}
+
 func (f *fakeUAAClient) SetUser(user *uaaclient.User) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
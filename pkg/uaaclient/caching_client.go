@@ -0,0 +1,152 @@
+package uaaclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+type TokenChecker interface {
+	CheckToken(token string, ctx context.Context) (*User, error)
+}
+
+// CachingClient remembers the users returned by another TokenChecker until
+// their token expires, or for at most maxTTL. Failed checks are not cached.
+type CachingClient struct {
+	checker    TokenChecker
+	maxEntries int
+	maxTTL     time.Duration
+	now        func() time.Time
+	group      singleflight.Group
+	hits       metrics.Counter
+	misses     metrics.Counter
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key       string
+	user      User
+	expiresAt time.Time
+}
+
+// NewCachingClient wraps checker with a cache of at most maxEntries users.
+// Hit and miss counts are registered as uaa.token_cache.hits and
+// uaa.token_cache.misses in registry, or in metrics.DefaultRegistry if it is
+// nil.
+func NewCachingClient(checker TokenChecker, maxEntries int, maxTTL time.Duration, registry metrics.Registry) *CachingClient {
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+
+	return &CachingClient{
+		checker:    checker,
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		now:        time.Now,
+		hits:       metrics.GetOrRegisterCounter("uaa.token_cache.hits", registry),
+		misses:     metrics.GetOrRegisterCounter("uaa.token_cache.misses", registry),
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (c *CachingClient) CheckToken(token string, ctx context.Context) (*User, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	user, ok := c.get(key)
+	if ok {
+		c.hits.Inc(1)
+		return user, nil
+	}
+
+	c.misses.Inc(1)
+
+	// concurrent requests carrying the same token share a single check
+	v, err := sharedCall(&c.group, key, ctx, func(ctx context.Context) (interface{}, error) {
+		// a check for this token may have finished since we looked
+		user, ok := c.get(key)
+		if ok {
+			return user, nil
+		}
+
+		user, err := c.checker.CheckToken(token, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.add(key, *user)
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := *v.(*User)
+	return &u, nil
+}
+
+func (c *CachingClient) get(key string) (*User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+
+	u := entry.user
+	return &u, true
+}
+
+func (c *CachingClient) add(key string, user User) {
+	expiresAt := c.now().Add(c.maxTTL)
+	if user.Expiry != 0 {
+		tokenExpiry := time.Unix(user.Expiry, 0)
+		if tokenExpiry.Before(expiresAt) {
+			expiresAt = tokenExpiry
+		}
+	}
+
+	if !c.now().Before(expiresAt) || c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if ok {
+		c.order.Remove(e)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{
+		key:       key,
+		user:      user,
+		expiresAt: expiresAt,
+	})
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,183 @@
+package uaaclient_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rcrowley/go-metrics"
+)
+
+type countingChecker struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	expiry  int64
+	err     error
+	release chan struct{}
+}
+
+func (c *countingChecker) CheckToken(token string, ctx context.Context) (*uaaclient.User, error) {
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls[token]++
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &uaaclient.User{ID: "user-for-" + token, Expiry: c.expiry}, nil
+}
+
+func (c *countingChecker) Calls(token string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[token]
+}
+
+var _ = Describe("CachingClient", func() {
+	var (
+		checker  *countingChecker
+		registry metrics.Registry
+	)
+
+	BeforeEach(func() {
+		checker = &countingChecker{
+			calls:  map[string]int{},
+			expiry: time.Now().Add(time.Hour).Unix(),
+		}
+		registry = metrics.NewRegistry()
+	})
+
+	It("caches users and counts hits and misses", func() {
+		client := uaaclient.NewCachingClient(checker, 10, time.Hour, registry)
+
+		for i := 0; i < 3; i++ {
+			user, err := client.CheckToken("token-a", context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.ID).To(Equal("user-for-token-a"))
+		}
+
+		Expect(checker.Calls("token-a")).To(Equal(1))
+		Expect(metrics.GetOrRegisterCounter("uaa.token_cache.hits", registry).Count()).To(Equal(int64(2)))
+		Expect(metrics.GetOrRegisterCounter("uaa.token_cache.misses", registry).Count()).To(Equal(int64(1)))
+	})
+
+	It("does not cache tokens past their expiry", func() {
+		checker.expiry = time.Now().Add(-time.Second).Unix()
+		client := uaaclient.NewCachingClient(checker, 10, time.Hour, registry)
+
+		client.CheckToken("token-a", context.Background())
+		client.CheckToken("token-a", context.Background())
+
+		Expect(checker.Calls("token-a")).To(Equal(2))
+	})
+
+	It("does not cache entries past the max ttl", func() {
+		client := uaaclient.NewCachingClient(checker, 10, 50*time.Millisecond, registry)
+
+		client.CheckToken("token-a", context.Background())
+		client.CheckToken("token-a", context.Background())
+		Expect(checker.Calls("token-a")).To(Equal(1))
+
+		time.Sleep(60 * time.Millisecond)
+
+		client.CheckToken("token-a", context.Background())
+		Expect(checker.Calls("token-a")).To(Equal(2))
+	})
+
+	It("evicts the least recently used entry", func() {
+		client := uaaclient.NewCachingClient(checker, 2, time.Hour, registry)
+
+		client.CheckToken("token-a", context.Background())
+		client.CheckToken("token-b", context.Background())
+		client.CheckToken("token-a", context.Background())
+		client.CheckToken("token-c", context.Background())
+
+		client.CheckToken("token-a", context.Background())
+		Expect(checker.Calls("token-a")).To(Equal(1))
+
+		client.CheckToken("token-b", context.Background())
+		Expect(checker.Calls("token-b")).To(Equal(2))
+	})
+
+	It("does not cache errors", func() {
+		checker.err = errors.New("uaa error")
+		client := uaaclient.NewCachingClient(checker, 10, time.Hour, registry)
+
+		_, err := client.CheckToken("token-a", context.Background())
+		Expect(err).To(MatchError("uaa error"))
+		_, err = client.CheckToken("token-a", context.Background())
+		Expect(err).To(MatchError("uaa error"))
+
+		Expect(checker.Calls("token-a")).To(Equal(2))
+	})
+
+	It("collapses concurrent checks of the same token", func() {
+		checker.release = make(chan struct{})
+		client := uaaclient.NewCachingClient(checker, 10, time.Hour, registry)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				user, err := client.CheckToken("token-a", context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(user.ID).To(Equal("user-for-token-a"))
+			}()
+		}
+
+		Eventually(func() int64 {
+			return metrics.GetOrRegisterCounter("uaa.token_cache.misses", registry).Count()
+		}).Should(Equal(int64(5)))
+		close(checker.release)
+		wg.Wait()
+
+		Expect(checker.Calls("token-a")).To(Equal(1))
+	})
+
+	It("does not fail shared checks when the first caller goes away", func() {
+		checker.release = make(chan struct{})
+		client := uaaclient.NewCachingClient(checker, 10, time.Hour, registry)
+		misses := func() int64 {
+			return metrics.GetOrRegisterCounter("uaa.token_cache.misses", registry).Count()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := client.CheckToken("token-a", ctx)
+			firstErr <- err
+		}()
+		Eventually(misses).Should(Equal(int64(1)))
+
+		secondErr := make(chan error, 1)
+		go func() {
+			_, err := client.CheckToken("token-a", context.Background())
+			secondErr <- err
+		}()
+		Eventually(misses).Should(Equal(int64(2)))
+
+		cancel()
+		Eventually(firstErr).Should(Receive(MatchError(context.Canceled)))
+
+		close(checker.release)
+		Eventually(secondErr).Should(Receive(BeNil()))
+		Expect(checker.Calls("token-a")).To(Equal(1))
+	})
+})
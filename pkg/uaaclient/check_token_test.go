@@ -34,6 +34,7 @@ var _ = Describe("UAAClient - CheckToken", func() {
 			Scopes:   []string{"notifications.write"},
 			Username: "admin",
 			Email:    "test@example.com",
+			Expiry:   1513376407,
 		}))
 		Expect(err).ToNot(HaveOccurred())
 
@@ -49,9 +50,7 @@ var _ = Describe("UAAClient - CheckToken", func() {
 
 	It("returns an error if the endpoint returns a non-200 status code", func() {
 		mux := http.NewServeMux()
-		var receivedRequest *http.Request
 		mux.HandleFunc("/check_token", func(w http.ResponseWriter, r *http.Request) {
-			receivedRequest = r
 			w.WriteHeader(http.StatusBadRequest)
 		})
 
@@ -67,9 +66,7 @@ var _ = Describe("UAAClient - CheckToken", func() {
 
 	It("returns an error if the user cannot be deserialized", func() {
 		mux := http.NewServeMux()
-		var receivedRequest *http.Request
 		mux.HandleFunc("/check_token", func(w http.ResponseWriter, r *http.Request) {
-			receivedRequest = r
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("}"))
 		})
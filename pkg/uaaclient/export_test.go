@@ -0,0 +1,11 @@
+package uaaclient
+
+import "time"
+
+func (v *TokenVerifier) SetNow(now func() time.Time) {
+	v.now = now
+}
+
+func (f *TokenFetcher) SetNow(now func() time.Time) {
+	f.now = now
+}
@@ -0,0 +1,30 @@
+package uaaclient
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const sharedCallTimeout = 30 * time.Second
+
+// sharedCall runs fn once for all concurrent callers using the same key. fn
+// gets a context with the values of ctx that is not cancelled along with it,
+// so the caller that happened to start the call going away does not fail the
+// others. Each caller stops waiting once its own ctx is done.
+func sharedCall(group *singleflight.Group, key string, ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	result := group.DoChan(key, func() (interface{}, error) {
+		detached, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+
+		return fn(detached)
+	})
+
+	select {
+	case r := <-result:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,171 @@
+package uaaclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	fetchAttempts  = 3
+	initialBackoff = 100 * time.Millisecond
+	maxRefreshLead = time.Minute
+	retryInterval  = 5 * time.Second
+)
+
+// TokenFetcher gets client_credentials tokens from UAA for the client the
+// UAAClient was created with. Tokens are cached and refreshed shortly before
+// they expire. Only one refresh runs at a time, and after a failed refresh the
+// next one waits for retryInterval.
+type TokenFetcher struct {
+	client *UAAClient
+	now    func() time.Time
+	group  singleflight.Group
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+	retryAt   time.Time
+	lastErr   error
+}
+
+func NewTokenFetcher(client *UAAClient) *TokenFetcher {
+	return &TokenFetcher{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// FetchAuthToken returns a token, or an empty string if none could be
+// fetched. Use Token to find out why a fetch failed.
+func (f *TokenFetcher) FetchAuthToken() string {
+	token, _ := f.Token(context.Background())
+	return token
+}
+
+// Token returns the cached token while it is valid, starting a refresh in the
+// background once it is about to expire. Callers only wait for UAA when there
+// is no valid token to hand out.
+func (f *TokenFetcher) Token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	token, expiresAt, refreshAt, retryAt, lastErr := f.token, f.expiresAt, f.refreshAt, f.retryAt, f.lastErr
+	f.mu.Unlock()
+
+	now := f.now()
+	if token != "" && now.Before(expiresAt) {
+		if !now.Before(refreshAt) && !now.Before(retryAt) {
+			go sharedCall(&f.group, "token", context.Background(), f.refresh)
+		}
+
+		return token, nil
+	}
+
+	if lastErr != nil && now.Before(retryAt) {
+		return "", lastErr
+	}
+
+	v, err := sharedCall(&f.group, "token", ctx, f.refresh)
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (f *TokenFetcher) refresh(ctx context.Context) (interface{}, error) {
+	token, lifetime, err := f.fetchWithRetries(ctx)
+	now := f.now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		f.retryAt = now.Add(retryInterval)
+		f.lastErr = err
+
+		return nil, err
+	}
+
+	lead := lifetime / 2
+	if lead > maxRefreshLead {
+		lead = maxRefreshLead
+	}
+
+	f.token = token
+	f.expiresAt = now.Add(lifetime)
+	f.refreshAt = f.expiresAt.Add(-lead)
+	f.retryAt = time.Time{}
+	f.lastErr = nil
+
+	return token, nil
+}
+
+func (f *TokenFetcher) fetchWithRetries(ctx context.Context) (string, time.Duration, error) {
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		token, lifetime, retry, err := f.fetch(ctx)
+		if err == nil {
+			return token, lifetime, nil
+		}
+
+		if !retry || attempt == fetchAttempts {
+			return "", 0, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetch asks UAA for a new token once. retry is false for failures that will
+// not go away by asking again, such as bad client credentials.
+func (f *TokenFetcher) fetch(ctx context.Context) (token string, lifetime time.Duration, retry bool, err error) {
+	req, err := f.client.client.PostRequest("/oauth/token", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+	req.SetBasicAuth(f.client.client_id, f.client.client_secret)
+
+	resp, err := f.client.client.Do(req, ctx)
+	if err != nil {
+		return "", 0, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", 0, resp.StatusCode >= 500, fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+
+	var body tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	if body.AccessToken == "" {
+		return "", 0, false, errors.New("response did not contain an access token")
+	}
+
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, false, nil
+}
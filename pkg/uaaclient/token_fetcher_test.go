@@ -0,0 +1,166 @@
+package uaaclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenFetcher", func() {
+	var (
+		requests  int32
+		failures  int32
+		status    int32
+		expiresIn int32
+		ts        *httptest.Server
+		fetcher   *uaaclient.TokenFetcher
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failures, 0)
+		atomic.StoreInt32(&status, http.StatusInternalServerError)
+		atomic.StoreInt32(&expiresIn, 3600)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&requests, 1)
+
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.ParseForm()).To(Succeed())
+			Expect(r.PostForm.Get("grant_type")).To(Equal("client_credentials"))
+			username, password, ok := r.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("client_id"))
+			Expect(password).To(Equal("client_secret"))
+
+			if atomic.LoadInt32(&failures) > 0 {
+				atomic.AddInt32(&failures, -1)
+				w.WriteHeader(int(atomic.LoadInt32(&status)))
+				return
+			}
+
+			fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`, n, atomic.LoadInt32(&expiresIn))
+		})
+		ts = httptest.NewServer(mux)
+
+		client, err := uaaclient.New(ts.URL, true, "client_id", "client_secret")
+		Expect(err).ToNot(HaveOccurred())
+
+		fetcher = uaaclient.NewTokenFetcher(client)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("fetches a token and caches it", func() {
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("refreshes the token before it expires", func() {
+		atomic.StoreInt32(&expiresIn, 1)
+
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+
+		time.Sleep(600 * time.Millisecond)
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		Eventually(fetcher.FetchAuthToken).Should(Equal("token-2"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+	})
+
+	It("retries server errors with backoff", func() {
+		atomic.StoreInt32(&failures, 2)
+
+		token, err := fetcher.Token(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("token-3"))
+	})
+
+	It("gives up after several attempts", func() {
+		atomic.StoreInt32(&failures, 6)
+
+		_, err := fetcher.Token(context.Background())
+		Expect(err).To(MatchError("response status code: 500"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+
+		By("not asking again straight away")
+		Expect(fetcher.FetchAuthToken()).To(BeEmpty())
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("does not retry rejected credentials", func() {
+		atomic.StoreInt32(&failures, 1)
+		atomic.StoreInt32(&status, http.StatusUnauthorized)
+
+		_, err := fetcher.Token(context.Background())
+		Expect(err).To(MatchError("response status code: 401"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("keeps using the cached token while it is valid if a refresh fails", func() {
+		atomic.StoreInt32(&expiresIn, 2)
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+
+		time.Sleep(1100 * time.Millisecond)
+		atomic.StoreInt32(&failures, 3)
+
+		token, err := fetcher.Token(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+	})
+
+	It("hands out the cached token while a single refresh runs", func() {
+		clock := &fakeClock{now: time.Now()}
+		fetcher.SetNow(clock.Now)
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+
+		clock.Advance(59*time.Minute + time.Second)
+		atomic.StoreInt32(&failures, 3)
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		}
+		Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+		Eventually(func() int32 { return atomic.LoadInt32(&requests) }).Should(Equal(int32(4)))
+
+		By("waiting before refreshing again after a failure")
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		Consistently(func() int32 { return atomic.LoadInt32(&requests) }).Should(Equal(int32(4)))
+
+		clock.Advance(5 * time.Second)
+		Expect(fetcher.FetchAuthToken()).To(Equal("token-1"))
+		Eventually(fetcher.FetchAuthToken).Should(Equal("token-5"))
+	})
+})
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,281 @@
+package uaaclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/httpclient"
+	"golang.org/x/sync/singleflight"
+)
+
+// keyRefreshInterval is the least time between refreshes of the keys for
+// unknown key ids, so tokens with made up key ids cannot flood UAA.
+const keyRefreshInterval = 30 * time.Second
+
+// TokenVerifier checks UAA issued JWTs locally against the keys published at
+// /token_keys instead of asking UAA about every token.
+type TokenVerifier struct {
+	client   *httpclient.HTTPClient
+	issuer   string
+	audience string
+	now      func() time.Time
+	group    singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	loaded      bool
+	lastRefresh time.Time
+}
+
+func NewTokenVerifier(host string, skipSSLValidation bool, issuer string, audience string) (*TokenVerifier, error) {
+	client, err := httpclient.New(host, skipSSLValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuer == "" {
+		return nil, errors.New("issuer must be set")
+	}
+
+	if audience == "" {
+		return nil, errors.New("audience must be set")
+	}
+
+	return &TokenVerifier{
+		client:   client,
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+		keys:     map[string]*rsa.PublicKey{},
+	}, nil
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type tokenClaims struct {
+	User
+	Issuer   string   `json:"iss"`
+	Audience audience `json:"aud"`
+}
+
+// audience is either a single string or a list of strings in a JWT
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(b, &list)
+	if err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
+func (v *TokenVerifier) CheckToken(token string, ctx context.Context) (*User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+
+	var header tokenHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", header.Algorithm)
+	}
+
+	key, err := v.key(header.KeyID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return nil, errors.New("token signature is invalid")
+	}
+
+	var claims tokenClaims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.now().Before(time.Unix(claims.User.Expiry, 0)) {
+		return nil, errors.New("token has expired")
+	}
+
+	if claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected token issuer: %s", claims.Issuer)
+	}
+
+	if !contains(claims.Audience, v.audience) {
+		return nil, fmt.Errorf("token audience does not include %s", v.audience)
+	}
+
+	return &claims.User, nil
+}
+
+// key returns the public key for kid, refetching the keys from UAA when kid
+// is not known yet. Requests that miss at the same time share a single fetch,
+// and once the keys are loaded they are refetched at most once per
+// keyRefreshInterval.
+func (v *TokenVerifier) key(kid string, ctx context.Context) (*rsa.PublicKey, error) {
+	key, ok := v.cachedKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	_, err := sharedCall(&v.group, "token_keys", ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, v.refreshKeys(ctx)
+	})
+
+	key, ok = v.cachedKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("unknown token key: %s", kid)
+}
+
+func (v *TokenVerifier) cachedKey(kid string) (*rsa.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *TokenVerifier) refreshKeys(ctx context.Context) error {
+	v.mu.Lock()
+	if v.loaded {
+		if v.now().Sub(v.lastRefresh) < keyRefreshInterval {
+			v.mu.Unlock()
+			return nil
+		}
+
+		// failed refreshes count too, or a UAA outage would be hit harder
+		v.lastRefresh = v.now()
+	}
+	v.mu.Unlock()
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = keys
+	v.loaded = true
+
+	return nil
+}
+
+type tokenKeys struct {
+	Keys []struct {
+		KeyType string `json:"kty"`
+		KeyID   string `json:"kid"`
+		N       string `json:"n"`
+		E       string `json:"e"`
+	} `json:"keys"`
+}
+
+func (v *TokenVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := v.client.GetRequest("/token_keys")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+
+	var body tokenKeys
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[k.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, target)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,218 @@
+package uaaclient_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/uaaclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenVerifier", func() {
+	const issuer = "https://uaa.example.com/oauth/token"
+
+	var (
+		key         *rsa.PrivateKey
+		keyID       string
+		keyRequests int32
+		ts          *httptest.Server
+		verifier    *uaaclient.TokenVerifier
+		validClaims map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		keyID = "key-1"
+		atomic.StoreInt32(&keyRequests, 0)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/token_keys", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&keyRequests, 1)
+			w.Write(tokenKeysResponse(keyID, &key.PublicKey))
+		})
+		ts = httptest.NewServer(mux)
+
+		verifier, err = uaaclient.NewTokenVerifier(ts.URL, true, issuer, "notifications")
+		Expect(err).ToNot(HaveOccurred())
+
+		validClaims = map[string]interface{}{
+			"user_id":   "some-user",
+			"user_name": "admin",
+			"email":     "test@example.com",
+			"scope":     []string{"notifications.write"},
+			"iss":       issuer,
+			"aud":       []string{"notifications", "openid"},
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		}
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("returns the user for a valid token", func() {
+		user, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(user).To(Equal(&uaaclient.User{
+			ID:       "some-user",
+			Scopes:   []string{"notifications.write"},
+			Username: "admin",
+			Email:    "test@example.com",
+			Expiry:   validClaims["exp"].(int64),
+		}))
+	})
+
+	It("caches the token keys", func() {
+		for i := 0; i < 3; i++ {
+			_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(atomic.LoadInt32(&keyRequests)).To(Equal(int32(1)))
+	})
+
+	It("refreshes the token keys when it sees an unknown key id", func() {
+		_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		By("rotating the key in uaa")
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		keyID = "key-2"
+
+		_, err = verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&keyRequests)).To(Equal(int32(2)))
+
+		By("using a key id uaa does not know about")
+		_, err = verifier.CheckToken(signToken(key, "key-3", validClaims), context.Background())
+		Expect(err).To(MatchError("unknown token key: key-3"))
+	})
+
+	It("refreshes the token keys at most once per interval for unknown key ids", func() {
+		now := time.Now()
+		verifier.SetNow(func() time.Time { return now })
+
+		_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				_, err := verifier.CheckToken(signToken(key, fmt.Sprintf("made-up-%d", i), validClaims), context.Background())
+				Expect(err).To(MatchError(fmt.Sprintf("unknown token key: made-up-%d", i)))
+			}(i)
+		}
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&keyRequests)).To(Equal(int32(2)))
+
+		By("still verifying tokens with known keys")
+		_, err = verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&keyRequests)).To(Equal(int32(2)))
+
+		By("refreshing again once the interval has passed")
+		now = now.Add(31 * time.Second)
+		_, err = verifier.CheckToken(signToken(key, "made-up", validClaims), context.Background())
+		Expect(err).To(MatchError("unknown token key: made-up"))
+		Expect(atomic.LoadInt32(&keyRequests)).To(Equal(int32(3)))
+	})
+
+	It("rejects tokens with an invalid signature", func() {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = verifier.CheckToken(signToken(otherKey, keyID, validClaims), context.Background())
+		Expect(err).To(MatchError("token signature is invalid"))
+	})
+
+	It("rejects expired tokens", func() {
+		validClaims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+		_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).To(MatchError("token has expired"))
+	})
+
+	It("rejects tokens from another issuer", func() {
+		validClaims["iss"] = "https://evil.example.com/oauth/token"
+
+		_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects tokens for another audience", func() {
+		validClaims["aud"] = "cloud_controller"
+
+		_, err := verifier.CheckToken(signToken(key, keyID, validClaims), context.Background())
+		Expect(err).To(MatchError("token audience does not include notifications"))
+	})
+
+	It("rejects tokens that are not JWTs", func() {
+		_, err := verifier.CheckToken("not-a-jwt", context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the issuer is blank", func() {
+		_, err := uaaclient.NewTokenVerifier(ts.URL, true, "", "notifications")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the audience is blank", func() {
+		_, err := uaaclient.NewTokenVerifier(ts.URL, true, issuer, "")
+		Expect(err).To(MatchError("audience must be set"))
+	})
+})
+
+func signToken(key *rsa.PrivateKey, keyID string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	Expect(err).ToNot(HaveOccurred())
+
+	payload, err := json.Marshal(claims)
+	Expect(err).ToNot(HaveOccurred())
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	Expect(err).ToNot(HaveOccurred())
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func tokenKeysResponse(keyID string, key *rsa.PublicKey) []byte {
+	b, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": keyID,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	return b
+}
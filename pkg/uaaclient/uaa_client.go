@@ -11,6 +11,7 @@ type User struct {
 	Scopes   []string `json:"scope"`
 	Email    string   `json:"email"`
 	Username string   `json:"user_name"`
+	Expiry   int64    `json:"exp,omitempty"`
 }
 
 type UAAClient struct {
@@ -0,0 +1,179 @@
+package viewer
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	layoutsDirectory  = "layouts"
+	partialsDirectory = "partials"
+	extension         = ".html"
+)
+
+// Config describes where a Renderer finds its templates. Pages are the .html
+// files anywhere in FS, named by their path without the extension,
+// e.g. "emails/unsubscribe". Files under layouts/ and partials/ are shared by
+// every page: a page can include "partials/footer" and a layout such as
+// "layouts/main" can include the "content" template a page defines.
+//
+// Funcs are available to all templates. With Reload set the templates are
+// parsed again for every render, so changes show up without a restart.
+//
+// A page that does not parse only fails when it is rendered, so other .html
+// files in FS, such as pages for a client side framework with its own {{ }}
+// syntax, do not break the rest. Layouts and partials are used by every page
+// and must parse.
+type Config struct {
+	FS     fs.FS
+	Funcs  template.FuncMap
+	Reload bool
+}
+
+type Renderer struct {
+	config Config
+	pages  map[string]page
+}
+
+type page struct {
+	template *template.Template
+	err      error
+}
+
+// New parses the templates in FS, so a broken layout or partial is found at
+// startup rather than on the first request. With Reload set New does not
+// fail on templates that do not parse, since they can be fixed before the
+// next render.
+func New(config Config) (*Renderer, error) {
+	r := &Renderer{config: config}
+
+	pages, err := r.parse()
+	if err != nil && !config.Reload {
+		return nil, err
+	}
+	r.pages = pages
+
+	return r, nil
+}
+
+// Render executes the page called name with data and writes it to w. If
+// layout is set the page is rendered inside "layouts/<layout>" instead. Nothing
+// is written if rendering fails.
+func (r *Renderer) Render(w io.Writer, name, layout string, data interface{}) error {
+	// names come from handlers, which may have built them from user input
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	if layout != "" && !fs.ValidPath(layout) {
+		return fmt.Errorf("invalid layout name %q", layout)
+	}
+
+	pages, err := r.templates()
+	if err != nil {
+		return err
+	}
+
+	p, ok := pages[name]
+	if !ok {
+		return fmt.Errorf("template %q not found", name)
+	}
+	if p.err != nil {
+		return p.err
+	}
+	page := p.template
+
+	templateName := name
+	if layout != "" {
+		templateName = layoutsDirectory + "/" + layout
+		if page.Lookup(templateName) == nil {
+			return fmt.Errorf("layout %q not found", layout)
+		}
+	}
+
+	var b bytes.Buffer
+	err = page.ExecuteTemplate(&b, templateName, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.WriteTo(w)
+	return err
+}
+
+func (r *Renderer) templates() (map[string]page, error) {
+	if r.config.Reload {
+		return r.parse()
+	}
+
+	return r.pages, nil
+}
+
+func (r *Renderer) parse() (map[string]page, error) {
+	files, err := r.files()
+	if err != nil {
+		return nil, err
+	}
+
+	shared := template.New("").Funcs(r.config.Funcs)
+	for name, content := range files {
+		if !isShared(name) {
+			continue
+		}
+
+		_, err := shared.New(name).Parse(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pages := map[string]page{}
+	for name, content := range files {
+		if isShared(name) {
+			continue
+		}
+
+		t, err := shared.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = t.New(name).Parse(content)
+		pages[name] = page{template: t, err: err}
+	}
+
+	return pages, nil
+}
+
+// files reads every template in FS, keyed by its name.
+func (r *Renderer) files() (map[string]string, error) {
+	files := map[string]string{}
+
+	err := fs.WalkDir(r.config.FS, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || path.Ext(name) != extension {
+			return nil
+		}
+
+		content, err := fs.ReadFile(r.config.FS, name)
+		if err != nil {
+			return err
+		}
+
+		files[strings.TrimSuffix(name, extension)] = string(content)
+		return nil
+	})
+
+	return files, err
+}
+
+func isShared(name string) bool {
+	return strings.HasPrefix(name, layoutsDirectory+"/") || strings.HasPrefix(name, partialsDirectory+"/")
+}
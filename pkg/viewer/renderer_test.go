@@ -0,0 +1,161 @@
+package viewer_test
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+
+	"github.com/cloudfoundry-incubator/go-cf-http-api/pkg/viewer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	var dir string
+
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	render := func(r *viewer.Renderer, name, layout string, data interface{}) (string, error) {
+		var b strings.Builder
+		err := r.Render(&b, name, layout, data)
+		return b.String(), err
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "templates")
+		Expect(err).ToNot(HaveOccurred())
+
+		write("layouts/main.html", `<main>{{template "content" .}}</main>`)
+		write("partials/greeting.html", `Hello {{.Name}}`)
+		write("unsubscribe.html", `{{define "content"}}{{template "partials/greeting" .}} from {{host}}{{end}}`)
+		write("emails/plain.html", `<p>{{.Name}}</p>`)
+		write("notes.txt", `not a template`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	newRenderer := func(reload bool) *viewer.Renderer {
+		r, err := viewer.New(viewer.Config{
+			FS:     os.DirFS(dir),
+			Funcs:  template.FuncMap{"host": func() string { return "example.com" }},
+			Reload: reload,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		return r
+	}
+
+	It("renders pages in nested directories, escaping the data", func() {
+		html, err := render(newRenderer(false), "emails/plain", "", map[string]string{"Name": "<script>"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<p>&lt;script&gt;</p>`))
+	})
+
+	It("renders pages inside layouts with partials and funcs", func() {
+		html, err := render(newRenderer(false), "unsubscribe", "main", map[string]string{"Name": "Ann"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<main>Hello Ann from example.com</main>`))
+	})
+
+	It("fails for unknown pages and layouts", func() {
+		r := newRenderer(false)
+
+		_, err := render(r, "missing", "", nil)
+		Expect(err).To(MatchError(`template "missing" not found`))
+
+		_, err = render(r, "unsubscribe", "missing", nil)
+		Expect(err).To(MatchError(`layout "missing" not found`))
+
+		_, err = render(r, "partials/greeting", "", nil)
+		Expect(err).To(MatchError(`template "partials/greeting" not found`))
+
+		_, err = render(r, "../unsubscribe", "", nil)
+		Expect(err).To(MatchError(`invalid template name "../unsubscribe"`))
+
+		_, err = render(r, "unsubscribe", "/etc/main", nil)
+		Expect(err).To(MatchError(`invalid layout name "/etc/main"`))
+	})
+
+	It("writes nothing when the template fails", func() {
+		write("broken.html", `before {{index .Items 5}}`)
+
+		html, err := render(newRenderer(false), "broken", "", map[string][]string{"Items": {}})
+		Expect(err).To(HaveOccurred())
+		Expect(html).To(BeEmpty())
+	})
+
+	It("reports layouts and partials that do not parse", func() {
+		write("partials/broken.html", `{{if}}`)
+
+		_, err := viewer.New(viewer.Config{FS: os.DirFS(dir)})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("only fails the pages that do not parse", func() {
+		write("app.html", `<div>{{ user.name | uppercase }}</div>`)
+		r := newRenderer(false)
+
+		_, err := render(r, "app", "", nil)
+		Expect(err).To(HaveOccurred())
+
+		html, err := render(r, "emails/plain", "", map[string]string{"Name": "Ann"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<p>Ann</p>`))
+	})
+
+	It("picks up fixes to broken templates when reloading", func() {
+		write("partials/greeting.html", `Hello {{if}}`)
+		r := newRenderer(true)
+
+		_, err := render(r, "emails/plain", "", map[string]string{"Name": "Ann"})
+		Expect(err).To(HaveOccurred())
+
+		write("partials/greeting.html", `Hello {{.Name}}`)
+
+		html, err := render(r, "emails/plain", "", map[string]string{"Name": "Ann"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<p>Ann</p>`))
+	})
+
+	It("reads templates from any fs.FS", func() {
+		r, err := viewer.New(viewer.Config{
+			FS: fstest.MapFS{
+				"layouts/main.html": {Data: []byte(`[{{template "content" .}}]`)},
+				"page.html":         {Data: []byte(`{{define "content"}}{{.}}{{end}}`)},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		html, err := render(r, "page", "main", "embedded")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`[embedded]`))
+	})
+
+	It("caches templates unless reloading", func() {
+		cached := newRenderer(false)
+		reloading := newRenderer(true)
+
+		write("emails/plain.html", `<b>{{.Name}}</b>`)
+
+		html, err := render(cached, "emails/plain", "", map[string]string{"Name": "Ann"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<p>Ann</p>`))
+
+		html, err = render(reloading, "emails/plain", "", map[string]string{"Name": "Ann"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(html).To(Equal(`<b>Ann</b>`))
+	})
+})
@@ -4,6 +4,9 @@ import (
 	"strings"
 )
 
+// Parse replaces each {{key}} in htmlTemplate with its value from metadata.
+//
+// Deprecated: Parse does not escape the values. Use a Renderer instead.
 func Parse(htmlTemplate string, metadata map[string]string) string {
 	for key, value := range metadata {
 		replace := "{{" + key + "}}"